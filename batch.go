@@ -0,0 +1,302 @@
+// Copyright 2025 FishGoddess. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package rego
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// reserveEntries takes n entries from idle resources and reserves the active for the rest of them.
+// It returns false if the pool doesn't have enough room for n resources, and nothing will be taken.
+// It should be called with lock held.
+func (p *Pool[Resource]) reserveEntries(n uint64) (idle []*entry[Resource], reserved uint64, ok bool) {
+	// New callers should queue behind the waiters in fair mode.
+	if p.queued() {
+		return nil, 0, false
+	}
+
+	room := uint64(len(p.idle)) + p.limit - min(p.limit, p.active)
+	if room < n {
+		return nil, 0, false
+	}
+
+	idle = make([]*entry[Resource], 0, n)
+	for uint64(len(idle)) < n {
+		entry, ok := p.acquireIdle()
+		if !ok {
+			break
+		}
+
+		idle = append(idle, entry)
+	}
+
+	reserved = n - uint64(len(idle))
+	p.active += reserved
+	return idle, reserved, true
+}
+
+// returnEntries returns the entries back to pool and releases the ones can't be reused.
+func (p *Pool[Resource]) returnEntries(ctx context.Context, entries []*entry[Resource]) {
+	var released []Resource
+
+	p.lock.Lock()
+	for _, entry := range entries {
+		if !p.reuse(entry) {
+			released = append(released, entry.resource)
+		}
+	}
+
+	p.lock.Unlock()
+
+	// There is nothing we can do with the errors returned since the acquiring has failed.
+	for _, resource := range released {
+		p.releasePending(ctx, resource)
+	}
+}
+
+// collectEntries checks the idle entries and acquires new entries for the reserved active.
+// All entries will be returned back to pool if some of them failed, and the quantity of discarded entries will be returned.
+func (p *Pool[Resource]) collectEntries(ctx context.Context, idle []*entry[Resource], stale []bool, reserved uint64) ([]*entry[Resource], uint64, error) {
+	entries := make([]*entry[Resource], 0, len(idle)+int(reserved))
+
+	var discarded uint64
+	var errs []error
+	for i, entry := range idle {
+		entry, err := p.checkEntry(ctx, entry, stale[i])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w: %w", ErrResourceUnavailable, err))
+			continue
+		}
+
+		if entry == nil {
+			discarded++
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	for ; reserved > 0; reserved-- {
+		if discarded > 0 || len(errs) > 0 {
+			break
+		}
+
+		entry, err := p.createEntry(ctx)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if discarded <= 0 && len(errs) <= 0 {
+		return entries, 0, nil
+	}
+
+	// Free the active reserved but not acquired, and return the acquired entries back to pool.
+	p.lock.Lock()
+	p.freeActive(reserved)
+	p.lock.Unlock()
+
+	p.returnEntries(ctx, entries)
+	return nil, discarded, errors.Join(errs...)
+}
+
+// waitEntries waits until the waiter is granted n idle entries or actives, and ok is false if the pool is closed.
+// The grants will be returned back to pool if the context is done or the pool is closed before all of them are granted.
+func (p *Pool[Resource]) waitEntries(ctx context.Context, w *waiter[Resource], n uint64) (grants []*entry[Resource], ok bool, err error) {
+	grants = make([]*entry[Resource], 0, n)
+
+wait:
+	for uint64(len(grants)) < n {
+		select {
+		case entry, granted := <-w.ready:
+			if !granted {
+				break wait
+			}
+
+			grants = append(grants, entry)
+		case <-ctx.Done():
+			err = ctx.Err()
+			break wait
+		}
+	}
+
+	if uint64(len(grants)) >= n {
+		return grants, true, nil
+	}
+
+	p.lock.Lock()
+	if w.index >= 0 {
+		heap.Remove(&p.waiters, w.index)
+	}
+
+	if p.holder == w {
+		p.holder = nil
+	}
+
+	// The waiter is removed from queue, so we take the grants left in channel without blocking.
+	for len(w.ready) > 0 {
+		grants = append(grants, <-w.ready)
+	}
+
+	var entries []*entry[Resource]
+	for _, entry := range grants {
+		if entry == nil {
+			p.freeActive(1)
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	p.lock.Unlock()
+
+	p.returnEntries(ctx, entries)
+	return nil, false, err
+}
+
+// acquireEntries acquires n entries from pool at once, and it waits until all of them are granted.
+// The waiter keeps the grants until it has n of them, so it won't be starved by the callers acquiring a single resource.
+func (p *Pool[Resource]) acquireEntries(ctx context.Context, n uint64) ([]*entry[Resource], error) {
+	var waited time.Duration
+	var discarded uint64
+
+	fail := func(err error) ([]*entry[Resource], error) {
+		return nil, &AcquireError{Err: err, Waited: waited, Discarded: discarded}
+	}
+
+	for {
+		p.lock.Lock()
+		if p.closed {
+			p.lock.Unlock()
+
+			err := p.closedErr(ctx)
+			return fail(err)
+		}
+
+		// The pool will never have enough room for n resources.
+		if n > p.limit {
+			p.lock.Unlock()
+			return fail(ErrPoolExhausted)
+		}
+
+		idle, reserved, ok := p.reserveEntries(n)
+		if !ok && p.overloaded() {
+			p.lock.Unlock()
+			return fail(ErrPoolExhausted)
+		}
+
+		if !ok {
+			w := p.enqueue(0, n)
+
+			// The room left in pool isn't enough for us, but we should keep it instead of leaving it to others.
+			for len(p.idle) > 0 && w.need > 0 {
+				entry, _ := p.acquireIdle()
+				p.handOver(entry)
+			}
+
+			p.grantActive()
+			waitCtx, cancel := p.waitContext(ctx)
+			p.lock.Unlock()
+
+			startTime := time.Now()
+			grants, granted, err := p.waitEntries(waitCtx, w, n)
+			endTime := time.Now()
+			waited += endTime.Sub(startTime)
+			cancel()
+
+			// The waiting times out fast since the pool is congested.
+			if err != nil && ctx.Err() == nil {
+				err = ErrPoolExhausted
+			}
+
+			p.lock.Lock()
+			p.waiting--
+			p.waited++
+			p.waitedDuration += endTime.Sub(startTime)
+			p.observeDelay(waited, endTime)
+
+			if errors.Is(err, context.DeadlineExceeded) {
+				p.lock.Unlock()
+				return fail(fmt.Errorf("%w: %w", ErrAcquireTimeout, err))
+			}
+
+			if err != nil {
+				p.lock.Unlock()
+				return fail(err)
+			}
+
+			// The pool is closed, so we should try again.
+			if !granted {
+				p.lock.Unlock()
+				continue
+			}
+
+			// The nil grants are actives reserved for us, and we should acquire new resources for them.
+			idle, reserved = nil, 0
+			for _, entry := range grants {
+				if entry == nil {
+					reserved++
+				} else {
+					idle = append(idle, entry)
+				}
+			}
+		}
+
+		now := time.Now()
+		stale := make([]bool, len(idle))
+		for i, entry := range idle {
+			stale[i] = p.idleTimedOut(entry, now) || p.retired(entry, now)
+		}
+
+		if len(idle) > 0 {
+			p.wakeFiller()
+		}
+
+		p.lock.Unlock()
+
+		entries, lost, err := p.collectEntries(ctx, idle, stale, reserved)
+		discarded += lost
+
+		if err != nil {
+			return fail(err)
+		}
+
+		// Some idle entries are discarded, so we should try again.
+		if entries == nil {
+			continue
+		}
+
+		return entries, nil
+	}
+}
+
+// AcquireN acquires n resources from pool at once and returns an error if failed.
+// It acquires all of them or none of them, so batch callers won't deadlock by holding part of the pool each.
+// You should call Pool.Release to return each resource back to the pool.
+func (p *Pool[Resource]) AcquireN(ctx context.Context, n uint64) ([]Resource, error) {
+	entries, err := p.acquireEntries(ctx, n)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := make([]Resource, 0, len(entries))
+
+	p.lock.Lock()
+	for _, entry := range entries {
+		p.track(entry)
+		resources = append(resources, entry.resource)
+	}
+
+	p.lock.Unlock()
+
+	return resources, nil
+}
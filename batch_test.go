@@ -0,0 +1,234 @@
+// Copyright 2025 FishGoddess. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package rego
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// go test -v -cover -run=^TestPoolAcquireN$
+func TestPoolAcquireN(t *testing.T) {
+	ctx := context.Background()
+
+	var acquired atomic.Int64
+	acquire := func(context.Context) (int64, error) { return acquired.Add(1), nil }
+	release := func(context.Context, int64) error { return nil }
+
+	pool := New(4, acquire, release)
+	defer pool.Close(ctx)
+
+	resources, err := pool.AcquireN(ctx, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resources) != 3 || resources[0] == resources[1] || resources[1] == resources[2] {
+		t.Fatalf("got %+v is wrong", resources)
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+
+	_, err = pool.AcquireN(timeoutCtx, 2)
+	if !errors.Is(err, ErrAcquireTimeout) {
+		t.Fatalf("got %+v != want %+v", err, ErrAcquireTimeout)
+	}
+
+	status := pool.Status()
+	if status.Using != 3 || status.Idle != 0 {
+		t.Fatalf("got %+v is wrong", status)
+	}
+
+	_, err = pool.AcquireN(ctx, 5)
+	if !errors.Is(err, ErrPoolExhausted) {
+		t.Fatalf("got %+v != want %+v", err, ErrPoolExhausted)
+	}
+
+	acquiredN := make(chan []int64, 1)
+	go func() {
+		resources, err := pool.AcquireN(ctx, 2)
+		if err != nil {
+			t.Error(err)
+		}
+
+		acquiredN <- resources
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+
+	if err = pool.Release(ctx, resources[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	got := <-acquiredN
+	if len(got) != 2 || got[0] != resources[0] {
+		t.Fatalf("got %+v is wrong", got)
+	}
+
+	status = pool.Status()
+	if status.Using != 4 || status.Idle != 0 {
+		t.Fatalf("got %+v is wrong", status)
+	}
+}
+
+// go test -v -cover -run=^TestPoolAcquireNError$
+func TestPoolAcquireNError(t *testing.T) {
+	ctx := context.Background()
+
+	var acquired atomic.Int64
+	acquire := func(context.Context) (int64, error) {
+		if acquired.Add(1) > 1 {
+			return 0, io.EOF
+		}
+
+		return 1, nil
+	}
+
+	release := func(context.Context, int64) error { return nil }
+
+	pool := New(4, acquire, release)
+	defer pool.Close(ctx)
+
+	_, err := pool.AcquireN(ctx, 2)
+	if !errors.Is(err, io.EOF) {
+		t.Fatalf("got %+v != want %+v", err, io.EOF)
+	}
+
+	status := pool.Status()
+	if status.Using != 0 || status.Idle != 1 {
+		t.Fatalf("got %+v is wrong", status)
+	}
+
+	if pool.active != 1 {
+		t.Fatalf("got %d != want %d", pool.active, 1)
+	}
+}
+
+// go test -v -cover -run=^TestPoolAcquireNDeadlock$
+func TestPoolAcquireNDeadlock(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	acquire := func(context.Context) (int, error) { return 0, nil }
+	release := func(context.Context, int) error { return nil }
+
+	pool := New(4, acquire, release)
+	defer pool.Close(ctx)
+
+	var wg sync.WaitGroup
+	for range 16 {
+		wg.Go(func() {
+			for range 16 {
+				resources, err := pool.AcquireN(ctx, 3)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+
+				time.Sleep(time.Millisecond)
+
+				for _, resource := range resources {
+					pool.Release(ctx, resource)
+				}
+			}
+		})
+	}
+
+	wg.Wait()
+
+	status := pool.Status()
+	if status.Using != 0 || status.Waiting != 0 {
+		t.Fatalf("got %+v is wrong", status)
+	}
+}
+
+// go test -v -cover -run=^TestPoolAcquireNStarvation$
+func TestPoolAcquireNStarvation(t *testing.T) {
+	ctx := context.Background()
+
+	acquire := func(context.Context) (int, error) { return 0, nil }
+	release := func(context.Context, int) error { return nil }
+
+	pool := New(4, acquire, release)
+	defer pool.Close(ctx)
+
+	var stopped atomic.Bool
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Go(func() {
+			for !stopped.Load() {
+				resource, err := pool.Acquire(ctx)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+
+				time.Sleep(time.Millisecond)
+				pool.Release(ctx, resource)
+			}
+		})
+	}
+
+	defer func() {
+		stopped.Store(true)
+		wg.Wait()
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	for range 16 {
+		resources, err := pool.AcquireN(timeoutCtx, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, resource := range resources {
+			pool.Release(ctx, resource)
+		}
+	}
+}
+
+// go test -v -cover -run=^TestPoolAcquireNCancel$
+func TestPoolAcquireNCancel(t *testing.T) {
+	ctx := context.Background()
+
+	acquire := func(context.Context) (int, error) { return 0, nil }
+	release := func(context.Context, int) error { return nil }
+
+	pool := New(2, acquire, release)
+	defer pool.Close(ctx)
+
+	resources, err := pool.AcquireN(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		pool.Release(ctx, resources[0])
+	}()
+
+	// The resource granted before timeout should be returned back to pool.
+	if _, err = pool.AcquireN(timeoutCtx, 2); !errors.Is(err, ErrAcquireTimeout) {
+		t.Fatalf("got %+v != want %+v", err, ErrAcquireTimeout)
+	}
+
+	status := pool.Status()
+	if status.Using != 1 || status.Idle != 1 || status.Waiting != 0 || pool.waiters.Len() != 0 {
+		t.Fatalf("got %+v is wrong", status)
+	}
+}
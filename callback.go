@@ -0,0 +1,85 @@
+// Copyright 2025 FishGoddess. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package rego
+
+import (
+	"context"
+)
+
+// All user callbacks are called by these functions, so a buggy callback won't wedge the whole pool by panicking.
+// The panics are converted to PanicErrors and the callers can restore the counters as usual.
+
+func (p *Pool[Resource]) acquireResource(ctx context.Context) (resource Resource, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = newPanicError(r)
+		}
+	}()
+
+	return p.acquire(ctx)
+}
+
+func (p *Pool[Resource]) releaseResource(ctx context.Context, resource Resource) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = newPanicError(r)
+		}
+	}()
+
+	return p.release(ctx, resource)
+}
+
+// availableResource returns false and a PanicError if the available function panics.
+func (p *Pool[Resource]) availableResource(ctx context.Context, resource Resource) (available bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			available = false
+			err = newPanicError(r)
+		}
+	}()
+
+	return p.available(ctx, resource), nil
+}
+
+func (p *Pool[Resource]) keepaliveResource(ctx context.Context, keepalive KeepaliveFunc[Resource], resource Resource) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = newPanicError(r)
+		}
+	}()
+
+	return keepalive(ctx, resource)
+}
+
+func (p *Pool[Resource]) closedErr(ctx context.Context) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = newPanicError(r)
+		}
+	}()
+
+	return p.newClosedErr(ctx)
+}
+
+// isFatal returns true and a PanicError if the fatal function panics.
+func isFatal(fatal FatalFunc, err error) (isFatal bool, panicErr error) {
+	defer func() {
+		if r := recover(); r != nil {
+			isFatal = true
+			panicErr = newPanicError(r)
+		}
+	}()
+
+	return fatal(err), nil
+}
+
+// notify calls the hook function with leak and the panic will be ignored because the hook is called in background.
+func notify[Resource any](hook LeakFunc[Resource], leak Leak[Resource]) {
+	defer func() {
+		recover()
+	}()
+
+	hook(leak)
+}
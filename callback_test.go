@@ -0,0 +1,194 @@
+// Copyright 2025 FishGoddess. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package rego
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func checkPanicError(t *testing.T, err error, value any) {
+	t.Helper()
+
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("got %+v is wrong", err)
+	}
+
+	if panicErr.Value != value {
+		t.Fatalf("got %+v != want %+v", panicErr.Value, value)
+	}
+
+	if len(panicErr.Stack) <= 0 {
+		t.Fatal("panicErr.Stack is empty")
+	}
+}
+
+// go test -v -cover -run=^TestPoolAcquirePanic$
+func TestPoolAcquirePanic(t *testing.T) {
+	ctx := context.Background()
+
+	acquire := func(context.Context) (int, error) { panic("acquire") }
+	release := func(context.Context, int) error { panic("release") }
+
+	pool := New(1, acquire, release)
+	defer pool.Close(ctx)
+
+	_, err := pool.Acquire(ctx)
+	checkPanicError(t, err, "acquire")
+
+	if pool.active != 0 {
+		t.Fatalf("active %d is wrong", pool.active)
+	}
+
+	err = pool.Warmup(ctx, 1, 1)
+	checkPanicError(t, err, "acquire")
+
+	if pool.active != 0 {
+		t.Fatalf("active %d is wrong", pool.active)
+	}
+
+	pool.Close(ctx)
+
+	err = pool.Release(ctx, 1)
+	checkPanicError(t, err, "release")
+}
+
+// go test -v -cover -run=^TestPoolAvailablePanic$
+func TestPoolAvailablePanic(t *testing.T) {
+	ctx := context.Background()
+
+	released := 0
+	acquire := func(context.Context) (int, error) { return 1, nil }
+	release := func(context.Context, int) error {
+		released++
+		return nil
+	}
+
+	available := func(context.Context, int) bool { panic("available") }
+
+	pool := New(1, acquire, release).WithAvailableFunc(available)
+	defer pool.Close(ctx)
+
+	resource, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool.Release(ctx, resource)
+
+	_, err = pool.Acquire(ctx)
+	checkPanicError(t, err, "available")
+
+	if released != 1 {
+		t.Fatalf("released %d is wrong", released)
+	}
+
+	status := pool.Status()
+	if status.Using != 0 || status.Idle != 0 || status.Discarded != 1 {
+		t.Fatalf("status %+v is wrong", status)
+	}
+
+	// The release error should be joined with the panic error.
+	pool.release = func(context.Context, int) error { return io.EOF }
+
+	resource, err = pool.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool.Release(ctx, resource)
+
+	_, err = pool.Acquire(ctx)
+	checkPanicError(t, err, "available")
+
+	if !errors.Is(err, io.EOF) {
+		t.Fatalf("got %+v is wrong", err)
+	}
+}
+
+// go test -v -cover -run=^TestPoolCallbacksPanic$
+func TestPoolCallbacksPanic(t *testing.T) {
+	ctx := context.Background()
+
+	acquire := func(context.Context) ([]int, error) { return []int{1}, nil }
+	release := func(context.Context, []int) error { return nil }
+	identity := func([]int) any { panic("identity") }
+	keepalive := func(context.Context, []int) error { panic("keepalive") }
+	onExpired := func(Leak[[]int]) { panic("expired") }
+	newClosedErr := func(context.Context) error { panic("closed") }
+
+	pool := New(1, acquire, release).WithIdentityFunc(identity).WithPoolClosedErrFunc(newClosedErr)
+	pool.WithLeaseTTL(time.Millisecond, onExpired, true)
+	defer pool.Close(ctx)
+
+	// The resource can't be tracked if identity function panics.
+	resource, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pool.tracked) != 0 || len(pool.known) != 0 {
+		t.Fatalf("tracked %+v, known %+v is wrong", pool.tracked, pool.known)
+	}
+
+	pool.Release(ctx, resource)
+
+	// The panic of hook shouldn't crash the process.
+	lease, err := pool.AcquireLease(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	lease.Release(ctx)
+
+	if err = pool.Warmup(ctx, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	pool.WithKeepalive(time.Millisecond, keepalive)
+	time.Sleep(10 * time.Millisecond)
+
+	status := pool.Status()
+	if status.Using != 0 || status.Idle != 0 || status.Discarded != 1 {
+		t.Fatalf("status %+v is wrong", status)
+	}
+
+	pool.Close(ctx)
+
+	_, err = pool.Acquire(ctx)
+	checkPanicError(t, err, "closed")
+}
+
+// go test -v -cover -run=^TestPoolDoFatalPanic$
+func TestPoolDoFatalPanic(t *testing.T) {
+	ctx := context.Background()
+
+	acquire := func(context.Context) (int, error) { return 1, nil }
+	release := func(context.Context, int) error { return nil }
+	fatal := func(error) bool { panic("fatal") }
+
+	pool := New(1, acquire, release).WithFatalFunc(fatal)
+	defer pool.Close(ctx)
+
+	err := pool.Do(ctx, func(ctx context.Context, resource int) error {
+		return io.EOF
+	})
+
+	checkPanicError(t, err, "fatal")
+
+	if !errors.Is(err, io.EOF) {
+		t.Fatalf("got %+v is wrong", err)
+	}
+
+	status := pool.Status()
+	if status.Using != 0 || status.Idle != 0 || status.Discarded != 1 {
+		t.Fatalf("status %+v is wrong", status)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2025 FishGoddess. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package rego
+
+import (
+	"context"
+	"time"
+)
+
+// WithCoDel sets the target and interval of controlled delay so the pool can protect the tail latency under overload.
+// The pool becomes congested if the min wait in an interval exceeds the target, and it recovers if not.
+// The waiters will be granted in LIFO order and time out after twice the target if the pool is congested.
+// A batch waiter holding part of its grants is still served first until it has all of them, see Pool.AcquireN.
+// So the fresh callers will be served and the stale ones will fail fast with ErrPoolExhausted.
+// A target <= 0 or an interval <= 0 means no controlled delay.
+func (p *Pool[Resource]) WithCoDel(target time.Duration, interval time.Duration) *Pool[Resource] {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if target <= 0 || interval <= 0 {
+		target = 0
+		interval = 0
+	}
+
+	p.delayTarget = target
+	p.delayInterval = interval
+	p.delayDeadline = time.Time{}
+	p.minDelay = 0
+	p.congested = false
+	return p
+}
+
+// observeDelay observes the delay of acquiring and updates the congestion at the end of every interval.
+// It should be called with lock held.
+func (p *Pool[Resource]) observeDelay(delay time.Duration, now time.Time) {
+	if p.delayTarget <= 0 {
+		return
+	}
+
+	if now.Before(p.delayDeadline) {
+		p.minDelay = min(p.minDelay, delay)
+		return
+	}
+
+	// The interval is over, so we check its min delay and start a new one.
+	p.congested = !p.delayDeadline.IsZero() && p.minDelay > p.delayTarget
+	p.minDelay = delay
+	p.delayDeadline = now.Add(p.delayInterval)
+}
+
+// newest returns the waiter enqueued latest.
+// It should be called with lock held.
+func (p *Pool[Resource]) newest() *waiter[Resource] {
+	newest := p.waiters[0]
+	for _, w := range p.waiters[1:] {
+		if w.seq > newest.seq {
+			newest = w
+		}
+	}
+
+	return newest
+}
+
+// waitContext returns the context of waiting, and it will time out after twice the target if the pool is congested.
+// It should be called with lock held.
+func (p *Pool[Resource]) waitContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if !p.congested {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, 2*p.delayTarget)
+}
@@ -0,0 +1,167 @@
+// Copyright 2025 FishGoddess. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package rego
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// go test -v -cover -run=^TestPoolObserveDelay$
+func TestPoolObserveDelay(t *testing.T) {
+	acquire := func(context.Context) (int, error) { return 0, nil }
+	release := func(context.Context, int) error { return nil }
+
+	pool := New(1, acquire, release).WithCoDel(10*time.Millisecond, 100*time.Millisecond)
+	now := time.Now()
+
+	testCases := []struct {
+		delay     time.Duration
+		after     time.Duration
+		congested bool
+	}{
+		{delay: 20 * time.Millisecond, after: 0, congested: false},
+		{delay: 30 * time.Millisecond, after: 50 * time.Millisecond, congested: false},
+		{delay: 15 * time.Millisecond, after: 150 * time.Millisecond, congested: true},
+		{delay: 0, after: 200 * time.Millisecond, congested: true},
+		{delay: 20 * time.Millisecond, after: 300 * time.Millisecond, congested: false},
+	}
+
+	for _, testCase := range testCases {
+		pool.observeDelay(testCase.delay, now.Add(testCase.after))
+
+		if pool.congested != testCase.congested {
+			t.Fatalf("after %s: got %+v != want %+v", testCase.after, pool.congested, testCase.congested)
+		}
+	}
+
+	pool.WithCoDel(0, time.Second)
+	pool.observeDelay(time.Hour, now)
+
+	if pool.congested || !pool.delayDeadline.IsZero() {
+		t.Fatalf("got %+v is wrong", pool.congested)
+	}
+}
+
+// go test -v -cover -run=^TestPoolCongested$
+func TestPoolCongested(t *testing.T) {
+	ctx := context.Background()
+
+	acquire := func(context.Context) (int, error) { return 0, nil }
+	release := func(context.Context, int) error { return nil }
+
+	pool := New(1, acquire, release).WithCoDel(10*time.Millisecond, time.Hour)
+	defer pool.Close(ctx)
+
+	pool.lock.Lock()
+	w1 := pool.enqueue(10, 1)
+	w2 := pool.enqueue(0, 1)
+	pool.congested = true
+	pool.handOver(nil)
+	pool.lock.Unlock()
+
+	// The fresh waiter is granted first even if its priority is lower.
+	select {
+	case <-w2.ready:
+	case <-w1.ready:
+		t.Fatal("the stale waiter is granted")
+	}
+
+	resource, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool.lock.Lock()
+	pool.waiters = nil
+	pool.waiting = 0
+	pool.congested = true
+	pool.delayDeadline = time.Now().Add(time.Hour)
+	pool.lock.Unlock()
+
+	// The waiter times out fast since the pool is congested.
+	begin := time.Now()
+	if _, err = pool.Acquire(ctx); !errors.Is(err, ErrPoolExhausted) {
+		t.Fatalf("got %+v != want %+v", err, ErrPoolExhausted)
+	}
+
+	if cost := time.Since(begin); cost < 20*time.Millisecond || cost > time.Second {
+		t.Fatalf("got %s is wrong", cost)
+	}
+
+	// The batch waiter times out fast too.
+	begin = time.Now()
+	if _, err = pool.AcquireN(ctx, 1); !errors.Is(err, ErrPoolExhausted) {
+		t.Fatalf("got %+v != want %+v", err, ErrPoolExhausted)
+	}
+
+	if cost := time.Since(begin); cost < 20*time.Millisecond || cost > time.Second {
+		t.Fatalf("got %s is wrong", cost)
+	}
+
+	if err = pool.Release(ctx, resource); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// go test -v -cover -run=^TestPoolCongestedAcquireN$
+func TestPoolCongestedAcquireN(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	acquire := func(context.Context) (int, error) { return 0, nil }
+	release := func(context.Context, int) error { return nil }
+
+	pool := New(4, acquire, release).WithCoDel(time.Hour, time.Hour)
+	defer pool.Close(ctx)
+
+	resources, err := pool.AcquireN(ctx, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool.lock.Lock()
+	pool.congested = true
+	pool.delayDeadline = time.Now().Add(time.Hour)
+	pool.lock.Unlock()
+
+	acquiredN := make(chan []int, 2)
+	for i := range 2 {
+		go func() {
+			resources, err := pool.AcquireN(ctx, 3)
+			if err != nil {
+				t.Error(err)
+			}
+
+			acquiredN <- resources
+		}()
+
+		time.Sleep(10 * time.Millisecond)
+
+		// The second batch waiter is newer, but it shouldn't split the grants with the first one.
+		for _, resource := range resources[2*i : 2*i+2] {
+			pool.Release(ctx, resource)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for range 2 {
+		got := <-acquiredN
+		if len(got) != 3 {
+			t.Fatalf("got %+v is wrong", got)
+		}
+
+		for _, resource := range got {
+			pool.Release(ctx, resource)
+		}
+	}
+
+	if status := pool.Status(); status.Using != 0 || status.Waiting != 0 {
+		t.Fatalf("got %+v is wrong", status)
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2025 FishGoddess. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package rego
+
+import (
+	"context"
+	"errors"
+)
+
+// FatalFunc is a function checks if an error is fatal to the resource, so the resource should be discarded.
+type FatalFunc func(err error) bool
+
+// WithFatalFunc sets the function checking if an error returned in Pool.Do is fatal to the resource.
+// By default, no errors are fatal and only the resources panicking in Pool.Do will be discarded.
+func (p *Pool[Resource]) WithFatalFunc(fatal FatalFunc) *Pool[Resource] {
+	if fatal != nil {
+		p.lock.Lock()
+		p.fatal = fatal
+		p.lock.Unlock()
+	}
+
+	return p
+}
+
+// call calls fn with resource and converts the panic to a PanicError.
+func call[Resource any](ctx context.Context, fn func(ctx context.Context, resource Resource) error, resource Resource) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = newPanicError(r)
+		}
+	}()
+
+	return fn(ctx, resource)
+}
+
+// Do acquires a resource from pool and calls fn with it, and the resource will always be returned to pool.
+// The resource will be discarded if fn panics or returns a fatal error, otherwise it will be released.
+// The panic will be recovered and returned as a PanicError, see Pool.WithFatalFunc for fatal errors.
+// The context passed to fn will also be canceled if the lease of resource is expired.
+func (p *Pool[Resource]) Do(ctx context.Context, fn func(ctx context.Context, resource Resource) error) error {
+	lease, err := p.AcquireLease(ctx)
+	if err != nil {
+		return err
+	}
+
+	doCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	leaseCtx := lease.Context()
+	stop := context.AfterFunc(leaseCtx, func() {
+		cancel(context.Cause(leaseCtx))
+	})
+
+	err = call(doCtx, fn, lease.Value())
+	stop()
+
+	p.lock.RLock()
+	fatal := p.fatal
+	p.lock.RUnlock()
+
+	var panicErr *PanicError
+	discard := errors.As(err, &panicErr)
+
+	if err != nil && !discard {
+		var fatalErr error
+		if discard, fatalErr = isFatal(fatal, err); fatalErr != nil {
+			err = errors.Join(err, fatalErr)
+		}
+	}
+
+	var returnErr error
+	if discard {
+		returnErr = lease.Discard(ctx)
+	} else {
+		returnErr = lease.Release(ctx)
+	}
+
+	if returnErr != nil {
+		err = errors.Join(err, returnErr)
+	}
+
+	return err
+}
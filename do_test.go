@@ -0,0 +1,188 @@
+// Copyright 2025 FishGoddess. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package rego
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// go test -v -cover -run=^TestWithFatalFunc$
+func TestWithFatalFunc(t *testing.T) {
+	fatal := func(error) bool {
+		return true
+	}
+
+	pool := &Pool[int]{fatal: nil}
+	pool.WithFatalFunc(fatal)
+
+	got := fmt.Sprintf("%p", pool.fatal)
+	want := fmt.Sprintf("%p", fatal)
+	if got != want {
+		t.Fatalf("got %s != want %s", got, want)
+	}
+
+	pool.WithFatalFunc(nil)
+
+	got = fmt.Sprintf("%p", pool.fatal)
+	want = fmt.Sprintf("%p", fatal)
+	if got != want {
+		t.Fatalf("got %s != want %s", got, want)
+	}
+}
+
+// go test -v -cover -run=^TestPoolDo$
+func TestPoolDo(t *testing.T) {
+	ctx := context.Background()
+
+	var acquired int64
+	var released int64
+	acquire := func(context.Context) (int64, error) { return atomic.AddInt64(&acquired, 1), nil }
+	release := func(context.Context, int64) error {
+		atomic.AddInt64(&released, 1)
+		return nil
+	}
+
+	fatal := func(err error) bool { return err == io.ErrUnexpectedEOF }
+
+	pool := New(1, acquire, release).WithFatalFunc(fatal)
+	defer pool.Close(ctx)
+
+	t.Run("ok", func(t *testing.T) {
+		err := pool.Do(ctx, func(ctx context.Context, resource int64) error {
+			if resource != 1 {
+				t.Fatalf("got %d != want %d", resource, 1)
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		status := pool.Status()
+		if status.Idle != 1 || status.Using != 0 || status.Discarded != 0 {
+			t.Fatalf("status %+v is wrong", status)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		err := pool.Do(ctx, func(ctx context.Context, resource int64) error {
+			return io.EOF
+		})
+
+		if err != io.EOF {
+			t.Fatalf("got %+v != want %+v", err, io.EOF)
+		}
+
+		status := pool.Status()
+		if status.Idle != 1 || status.Using != 0 || status.Discarded != 0 {
+			t.Fatalf("status %+v is wrong", status)
+		}
+	})
+
+	t.Run("fatal_error", func(t *testing.T) {
+		err := pool.Do(ctx, func(ctx context.Context, resource int64) error {
+			return io.ErrUnexpectedEOF
+		})
+
+		if err != io.ErrUnexpectedEOF {
+			t.Fatalf("got %+v != want %+v", err, io.ErrUnexpectedEOF)
+		}
+
+		status := pool.Status()
+		if status.Idle != 0 || status.Using != 0 || status.Discarded != 1 {
+			t.Fatalf("status %+v is wrong", status)
+		}
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		err := pool.Do(ctx, func(ctx context.Context, resource int64) error {
+			if resource != 2 {
+				t.Fatalf("got %d != want %d", resource, 2)
+			}
+
+			panic("wow")
+		})
+
+		var panicErr *PanicError
+		if !errors.As(err, &panicErr) {
+			t.Fatalf("got %+v is wrong", err)
+		}
+
+		if panicErr.Value != "wow" {
+			t.Fatalf("got %+v != want %+v", panicErr.Value, "wow")
+		}
+
+		if !strings.Contains(string(panicErr.Stack), "TestPoolDo") {
+			t.Fatalf("stack %s is wrong", panicErr.Stack)
+		}
+
+		status := pool.Status()
+		if status.Idle != 0 || status.Using != 0 || status.Discarded != 2 {
+			t.Fatalf("status %+v is wrong", status)
+		}
+	})
+
+	if got := atomic.LoadInt64(&released); got != 2 {
+		t.Fatalf("released %d is wrong", got)
+	}
+
+	pool.Close(ctx)
+
+	err := pool.Do(ctx, func(ctx context.Context, resource int64) error {
+		t.Fatal("do on closed pool")
+		return nil
+	})
+
+	if !errors.Is(err, ErrPoolClosed) {
+		t.Fatalf("got %+v != want %+v", err, ErrPoolClosed)
+	}
+}
+
+// go test -v -cover -run=^TestPoolDoLeaseExpired$
+func TestPoolDoLeaseExpired(t *testing.T) {
+	ctx := context.Background()
+
+	wantErr := errors.New("wow")
+	acquire := func(context.Context) (int, error) { return 0, nil }
+	release := func(context.Context, int) error { return wantErr }
+
+	pool := New(1, acquire, release).WithLeaseTTL(10*time.Millisecond, nil, false)
+	defer pool.Close(ctx)
+
+	err := pool.Do(ctx, func(ctx context.Context, resource int) error {
+		<-ctx.Done()
+
+		if cause := context.Cause(ctx); cause != ErrLeaseExpired {
+			t.Fatalf("got %+v != want %+v", cause, ErrLeaseExpired)
+		}
+
+		return io.ErrUnexpectedEOF
+	})
+
+	// The resource is put back to pool so the release function won't be called.
+	if !errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, wantErr) {
+		t.Fatalf("got %+v is wrong", err)
+	}
+
+	// The error returned by release function should be joined.
+	pool.WithFatalFunc(func(error) bool { return true })
+
+	err = pool.Do(ctx, func(ctx context.Context, resource int) error {
+		return io.ErrUnexpectedEOF
+	})
+
+	if !errors.Is(err, io.ErrUnexpectedEOF) || !errors.Is(err, wantErr) {
+		t.Fatalf("got %+v is wrong", err)
+	}
+}
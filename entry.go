@@ -0,0 +1,58 @@
+// Copyright 2025 FishGoddess. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package rego
+
+import (
+	"context"
+	"reflect"
+	"time"
+)
+
+// entry wraps a resource with some information stored in pool.
+type entry[Resource any] struct {
+	resource   Resource
+	key        any
+	keyed      bool
+	createdAt  time.Time
+	idleAt     time.Time
+	aliveAt    time.Time
+	acquiredAt time.Time
+	useCount   uint64
+
+	// stack is the stack of goroutine acquiring the entry, and it's recorded only if leak detection is enabled.
+	stack    []byte
+	reported bool
+
+	// cancel cancels the context of lease, and it's nil if the entry isn't acquired by lease.
+	cancel context.CancelCauseFunc
+
+	// jitter is subtracted from the max lifetime so resources won't be retired at the same time.
+	jitter time.Duration
+}
+
+// use marks the entry acquired by caller.
+func (e *entry[Resource]) use() {
+	e.acquiredAt = time.Now()
+	e.useCount++
+}
+
+// keyOf returns the key of resource used to track it and false if the resource can't be tracked.
+// Only comparable resources can be tracked because the key is used in a map.
+// The key will be returned by identity function if it's not nil.
+// The resource can't be tracked if the identity function panics.
+func keyOf[Resource any](resource Resource, identity IdentityFunc[Resource]) (key any, ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			key, ok = nil, false
+		}
+	}()
+
+	key = any(resource)
+	if identity != nil {
+		key = identity(resource)
+	}
+
+	return key, reflect.ValueOf(key).Comparable()
+}
@@ -0,0 +1,56 @@
+// Copyright 2025 FishGoddess. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package rego
+
+import (
+	"net"
+	"testing"
+)
+
+// go test -v -cover -run=^TestKeyOf$
+func TestKeyOf(t *testing.T) {
+	type testCase struct {
+		resource any
+		ok       bool
+	}
+
+	testCases := []testCase{
+		{resource: 1, ok: true},
+		{resource: "rego", ok: true},
+		{resource: &net.TCPConn{}, ok: true},
+		{resource: struct{ id int }{id: 1}, ok: true},
+		{resource: []int{1}, ok: false},
+		{resource: map[int]int{}, ok: false},
+		{resource: struct{ ids []int }{}, ok: false},
+		{resource: nil, ok: false},
+	}
+
+	for _, testCase := range testCases {
+		key, ok := keyOf(testCase.resource, nil)
+		if ok != testCase.ok {
+			t.Fatalf("resource %+v: got %+v != want %+v", testCase.resource, ok, testCase.ok)
+		}
+
+		if ok && key != testCase.resource {
+			t.Fatalf("got %+v != want %+v", key, testCase.resource)
+		}
+	}
+
+	var conn net.Conn = &net.TCPConn{}
+	if _, ok := keyOf(conn, nil); !ok {
+		t.Fatal("net.Conn should be tracked")
+	}
+
+	identity := func(ids []int) any { return ids[0] }
+
+	key, ok := keyOf([]int{1, 2, 3}, identity)
+	if !ok {
+		t.Fatal("resource with identity should be tracked")
+	}
+
+	if key != 1 {
+		t.Fatalf("got %+v != want %+v", key, 1)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2025 FishGoddess. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package rego
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+var (
+	// ErrPoolClosed is returned if the pool is closed.
+	ErrPoolClosed = errors.New("rego: pool is closed")
+
+	// ErrPoolExhausted is returned if the pool is exhausted and the caller can't wait for a resource.
+	ErrPoolExhausted = errors.New("rego: pool is exhausted")
+
+	// ErrAcquireTimeout is returned if the caller waits for a resource until its context is timed out.
+	ErrAcquireTimeout = errors.New("rego: acquire timeout")
+
+	// ErrResourceUnavailable is returned if the resource acquired is unavailable and it failed to release.
+	ErrResourceUnavailable = errors.New("rego: resource is unavailable")
+
+	// ErrDoubleRelease is returned in strict release mode if a resource is released more than once.
+	ErrDoubleRelease = errors.New("rego: resource is released more than once")
+
+	// ErrForeignResource is returned in strict release mode if a resource released isn't acquired from pool.
+	ErrForeignResource = errors.New("rego: resource isn't acquired from pool")
+
+	// ErrLeaseExpired is the cause of lease context canceled because the lease is held longer than its ttl.
+	ErrLeaseExpired = errors.New("rego: lease is expired")
+)
+
+// AcquireError is an error returned if acquiring a resource from pool failed.
+// Use errors.Is to check its cause, like ErrPoolClosed and ErrAcquireTimeout.
+type AcquireError struct {
+	// Err is the cause of acquiring failed.
+	Err error
+
+	// Waited is the duration waiting for a resource before failed.
+	Waited time.Duration
+
+	// Discarded is the quantity of stale or unavailable resources discarded before failed.
+	Discarded uint64
+}
+
+func (ae *AcquireError) Error() string {
+	return fmt.Sprintf("%s (waited %s, discarded %d)", ae.Err, ae.Waited, ae.Discarded)
+}
+
+// Unwrap returns the cause of acquiring failed.
+func (ae *AcquireError) Unwrap() error {
+	return ae.Err
+}
+
+// PanicError is an error converted from a panic.
+type PanicError struct {
+	// Value is the value passed to panic.
+	Value any
+
+	// Stack is the stack of goroutine when panicking.
+	Stack []byte
+}
+
+func newPanicError(r any) *PanicError {
+	return &PanicError{Value: r, Stack: debug.Stack()}
+}
+
+func (pe *PanicError) Error() string {
+	return fmt.Sprintf("rego: panic: %v", pe.Value)
+}
+
+// Unwrap returns the value passed to panic if it's an error.
+func (pe *PanicError) Unwrap() error {
+	err, _ := pe.Value.(error)
+	return err
+}
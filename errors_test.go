@@ -0,0 +1,107 @@
+// Copyright 2025 FishGoddess. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package rego
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// go test -v -cover -run=^TestAcquireError$
+func TestAcquireError(t *testing.T) {
+	acquireErr := &AcquireError{Err: ErrPoolClosed, Waited: time.Second, Discarded: 1}
+
+	want := "rego: pool is closed (waited 1s, discarded 1)"
+	if acquireErr.Error() != want {
+		t.Fatalf("got %s != want %s", acquireErr.Error(), want)
+	}
+
+	if !errors.Is(acquireErr, ErrPoolClosed) {
+		t.Fatalf("got %+v is wrong", acquireErr)
+	}
+
+	acquired := false
+	acquire := func(context.Context) (int, error) {
+		if acquired {
+			return 0, io.EOF
+		}
+
+		acquired = true
+		return 0, nil
+	}
+
+	ctx := context.Background()
+	release := func(context.Context, int) error { return nil }
+	available := func(context.Context, int) bool { return false }
+
+	pool := New(1, acquire, release).WithAvailableFunc(available)
+	defer pool.Close(ctx)
+
+	resource, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = pool.Release(ctx, resource); err != nil {
+		t.Fatal(err)
+	}
+
+	_, got := pool.Acquire(ctx)
+	if !errors.As(got, &acquireErr) {
+		t.Fatalf("got %+v is wrong", got)
+	}
+
+	if !errors.Is(got, io.EOF) || acquireErr.Discarded != 1 {
+		t.Fatalf("got %+v is wrong", acquireErr)
+	}
+
+	acquire = func(context.Context) (int, error) { return 0, nil }
+	pool = New(1, acquire, release)
+	defer pool.Close(ctx)
+
+	if _, err = pool.Acquire(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+
+	_, got = pool.Acquire(timeoutCtx)
+	if !errors.As(got, &acquireErr) || !errors.Is(got, ErrAcquireTimeout) {
+		t.Fatalf("got %+v is wrong", got)
+	}
+
+	if acquireErr.Waited < 10*time.Millisecond {
+		t.Fatalf("got %+v is wrong", acquireErr.Waited)
+	}
+}
+
+// go test -v -cover -run=^TestPanicError$
+func TestPanicError(t *testing.T) {
+	err := &PanicError{Value: "wow"}
+
+	want := "rego: panic: wow"
+	if err.Error() != want {
+		t.Fatalf("got %s != want %s", err.Error(), want)
+	}
+
+	if err.Unwrap() != nil {
+		t.Fatalf("got %+v is wrong", err.Unwrap())
+	}
+
+	err = &PanicError{Value: io.EOF}
+
+	want = "rego: panic: EOF"
+	if err.Error() != want {
+		t.Fatalf("got %s != want %s", err.Error(), want)
+	}
+
+	if !errors.Is(err, io.EOF) {
+		t.Fatalf("got %+v is wrong", err)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2025 FishGoddess. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package rego
+
+import (
+	"math/rand/v2"
+	"slices"
+)
+
+// IdleOrder is the order of reusing idle resources in pool.
+type IdleOrder int
+
+const (
+	// IdleFIFO reuses the resource idle for the longest time, so all resources will be used evenly.
+	IdleFIFO IdleOrder = iota
+
+	// IdleLIFO reuses the resource idle for the shortest time, so the cold resources will be timed out and released.
+	IdleLIFO
+
+	// IdleRandom reuses a random idle resource, so the load will be spread over all resources.
+	IdleRandom
+)
+
+// WithIdleOrder sets the order of reusing idle resources, and IdleFIFO is used by default.
+// Use IdleLIFO with Pool.WithIdleTimeout if you want the pool to shrink when it's not busy.
+func (p *Pool[Resource]) WithIdleOrder(order IdleOrder) *Pool[Resource] {
+	p.lock.Lock()
+	p.order = order
+	p.lock.Unlock()
+
+	return p
+}
+
+// acquireIdle takes an idle entry out of pool in order and returns false if there are no idle entries.
+// The idle entries are sorted by their idle time, so the first one is idle for the longest time.
+// It should be called with lock held.
+func (p *Pool[Resource]) acquireIdle() (*entry[Resource], bool) {
+	if len(p.idle) <= 0 {
+		return nil, false
+	}
+
+	index := 0
+	switch p.order {
+	case IdleLIFO:
+		index = len(p.idle) - 1
+	case IdleRandom:
+		index = rand.IntN(len(p.idle))
+	}
+
+	entry := p.idle[index]
+	p.idle = slices.Delete(p.idle, index, index+1)
+	return entry, true
+}
+
+// takeIdle takes the entry out of pool and returns false if the entry isn't idle.
+// It should be called with lock held.
+func (p *Pool[Resource]) takeIdle(entry *entry[Resource]) bool {
+	index := slices.Index(p.idle, entry)
+	if index < 0 {
+		return false
+	}
+
+	p.idle = slices.Delete(p.idle, index, index+1)
+	return true
+}
+
+// pushIdle puts the entry to pool as the latest idle one and returns false if the pool is full.
+// It should be called with lock held.
+func (p *Pool[Resource]) pushIdle(entry *entry[Resource]) bool {
+	if uint64(len(p.idle)) >= p.limit {
+		return false
+	}
+
+	p.idle = append(p.idle, entry)
+	return true
+}
+
+// insertIdle puts the entry back to pool in the order of its idle time.
+// It should be called with lock held.
+func (p *Pool[Resource]) insertIdle(entry *entry[Resource]) {
+	index := len(p.idle)
+	for index > 0 && p.idle[index-1].idleAt.After(entry.idleAt) {
+		index--
+	}
+
+	p.idle = slices.Insert(p.idle, index, entry)
+}
+
+// drainIdle takes all idle entries out of pool and forgets them.
+// It should be called with lock held.
+func (p *Pool[Resource]) drainIdle() []*entry[Resource] {
+	entries := p.idle
+	p.idle = nil
+
+	for _, entry := range entries {
+		p.forget(entry)
+	}
+
+	return entries
+}
@@ -0,0 +1,108 @@
+// Copyright 2025 FishGoddess. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package rego
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// go test -v -cover -run=^TestPoolIdleOrder$
+func TestPoolIdleOrder(t *testing.T) {
+	ctx := context.Background()
+
+	acquire := func(context.Context) (int, error) { return 0, nil }
+	release := func(context.Context, int) error { return nil }
+
+	testCases := []struct {
+		order IdleOrder
+		want  []int
+	}{
+		{order: IdleFIFO, want: []int{1, 2, 3}},
+		{order: IdleLIFO, want: []int{3, 2, 1}},
+	}
+
+	for _, testCase := range testCases {
+		pool := New(3, acquire, release).WithIdleOrder(testCase.order)
+		defer pool.Close(ctx)
+
+		for i := 1; i <= 3; i++ {
+			if err := pool.Release(ctx, i); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		for _, want := range testCase.want {
+			got, err := pool.Acquire(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != want {
+				t.Fatalf("order %d: got %d != want %d", testCase.order, got, want)
+			}
+		}
+	}
+
+	pool := New(3, acquire, release).WithIdleOrder(IdleRandom)
+	defer pool.Close(ctx)
+
+	for i := 1; i <= 3; i++ {
+		if err := pool.Release(ctx, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	acquired := make(map[int]struct{})
+	for range 3 {
+		got, err := pool.Acquire(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		acquired[got] = struct{}{}
+	}
+
+	if len(acquired) != 3 {
+		t.Fatalf("got %+v is wrong", acquired)
+	}
+}
+
+// go test -v -cover -run=^TestPoolInsertIdle$
+func TestPoolInsertIdle(t *testing.T) {
+	now := time.Now()
+
+	acquire := func(context.Context) (int, error) { return 0, nil }
+	release := func(context.Context, int) error { return nil }
+
+	pool := New(3, acquire, release)
+	for i := range 3 {
+		if !pool.pushIdle(&entry[int]{resource: i * 2, idleAt: now.Add(time.Duration(i*2) * time.Second)}) {
+			t.Fatal("push idle failed")
+		}
+	}
+
+	if pool.pushIdle(&entry[int]{resource: 6}) {
+		t.Fatal("push idle to a full pool")
+	}
+
+	pool.insertIdle(&entry[int]{resource: 3, idleAt: now.Add(3 * time.Second)})
+
+	want := []int{0, 2, 3, 4}
+	for i, entry := range pool.idle {
+		if entry.resource != want[i] {
+			t.Fatalf("got %d != want %d", entry.resource, want[i])
+		}
+	}
+
+	if !pool.takeIdle(pool.idle[1]) || pool.takeIdle(&entry[int]{}) {
+		t.Fatal("take idle is wrong")
+	}
+
+	if len(pool.idle) != 3 || pool.idle[1].resource != 3 {
+		t.Fatalf("got %+v is wrong", pool.idle)
+	}
+}
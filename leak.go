@@ -0,0 +1,125 @@
+// Copyright 2025 FishGoddess. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package rego
+
+import (
+	"context"
+	"runtime/debug"
+	"time"
+)
+
+// Leak is a resource which has been acquired but not released for a long time.
+type Leak[Resource any] struct {
+	// Resource is the resource leaked.
+	Resource Resource `json:"resource"`
+
+	// AcquiredAt is the time when the resource was acquired.
+	AcquiredAt time.Time `json:"acquired_at"`
+
+	// Duration is the duration since the resource was acquired.
+	Duration time.Duration `json:"duration"`
+
+	// Stack is the stack of goroutine acquiring the resource, and it's empty if leak detection isn't enabled.
+	Stack string `json:"stack"`
+}
+
+// LeakFunc is a function handles a leak detected.
+type LeakFunc[Resource any] func(leak Leak[Resource])
+
+// WithLeakDetection records the stack of goroutine acquiring each resource, and detects the resources acquired but not
+// released longer than threshold periodically. The onLeak function will be called once for each leak detected if it's
+// not nil. A threshold <= 0 means no leak detection.
+// Notice that recording stacks is expensive, so you'd better enable it only for debugging.
+func (p *Pool[Resource]) WithLeakDetection(threshold time.Duration, onLeak LeakFunc[Resource]) *Pool[Resource] {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.detector.stop()
+	p.detector = nil
+	p.leakTime = threshold
+	p.onLeak = onLeak
+
+	if threshold <= 0 || onLeak == nil || p.closed {
+		return p
+	}
+
+	// Detecting at half of the threshold so the leaks will be reported in time.
+	interval := threshold / 2
+	if interval <= 0 {
+		interval = threshold
+	}
+
+	p.detector = newTask(interval, p.detectLeaks)
+	go p.detector.run()
+	return p
+}
+
+// borrow marks the entry borrowed by caller.
+// It should be called with lock held.
+func (p *Pool[Resource]) borrow(entry *entry[Resource]) {
+	entry.stack = nil
+	entry.reported = false
+	entry.cancel = nil
+
+	if p.leakTime > 0 {
+		entry.stack = debug.Stack()
+	}
+
+	p.borrowed[entry] = struct{}{}
+}
+
+// leakOf returns the leak of entry acquired by caller.
+// It should be called with lock held because the entry may be acquired again once it's returned.
+func leakOf[Resource any](entry *entry[Resource], now time.Time) Leak[Resource] {
+	return Leak[Resource]{
+		Resource:   entry.resource,
+		AcquiredAt: entry.acquiredAt,
+		Duration:   now.Sub(entry.acquiredAt),
+		Stack:      string(entry.stack),
+	}
+}
+
+// leaks returns the leaks held longer than threshold and marks them reported if report is true.
+// It should be called with lock held.
+func (p *Pool[Resource]) leaks(threshold time.Duration, report bool) []Leak[Resource] {
+	now := time.Now()
+
+	var leaks []Leak[Resource]
+	for entry := range p.borrowed {
+		leak := leakOf(entry, now)
+		if leak.Duration < threshold || (report && entry.reported) {
+			continue
+		}
+
+		if report {
+			entry.reported = true
+		}
+
+		leaks = append(leaks, leak)
+	}
+
+	return leaks
+}
+
+// detectLeaks detects the leaks and reports them by the leak function.
+func (p *Pool[Resource]) detectLeaks(ctx context.Context) {
+	p.lock.Lock()
+	onLeak := p.onLeak
+	leaks := p.leaks(p.leakTime, true)
+	p.lock.Unlock()
+
+	for _, leak := range leaks {
+		notify(onLeak, leak)
+	}
+}
+
+// Leaks returns the resources acquired but not released longer than threshold.
+// Notice that resources which aren't comparable can't be tracked unless they are acquired by Pool.AcquireLease.
+func (p *Pool[Resource]) Leaks(threshold time.Duration) []Leak[Resource] {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.leaks(threshold, false)
+}
@@ -0,0 +1,140 @@
+// Copyright 2025 FishGoddess. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package rego
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// go test -v -cover -run=^TestWithLeakDetection$
+func TestWithLeakDetection(t *testing.T) {
+	ctx := context.Background()
+
+	acquire := func(context.Context) (int, error) { return 0, nil }
+	release := func(context.Context, int) error { return nil }
+	onLeak := func(Leak[int]) {}
+
+	pool := New(1, acquire, release).WithLeakDetection(time.Minute, onLeak)
+	defer pool.Close(ctx)
+
+	if pool.leakTime != time.Minute {
+		t.Fatalf("got %d != want %d", pool.leakTime, time.Minute)
+	}
+
+	if pool.detector == nil {
+		t.Fatal("pool.detector is nil")
+	}
+
+	pool.WithLeakDetection(time.Minute, nil)
+	if pool.detector != nil {
+		t.Fatalf("got %+v is wrong", pool.detector)
+	}
+
+	if pool.leakTime != time.Minute {
+		t.Fatalf("got %d != want %d", pool.leakTime, time.Minute)
+	}
+
+	pool.WithLeakDetection(0, onLeak)
+	if pool.detector != nil {
+		t.Fatalf("got %+v is wrong", pool.detector)
+	}
+}
+
+// go test -v -cover -run=^TestPoolLeaks$
+func TestPoolLeaks(t *testing.T) {
+	ctx := context.Background()
+
+	acquire := func(context.Context) ([]int, error) { return []int{1}, nil }
+	release := func(context.Context, []int) error { return nil }
+
+	pool := New(4, acquire, release)
+	defer pool.Close(ctx)
+
+	// Resources which aren't comparable can't be tracked unless they are acquired by lease.
+	if _, err := pool.Acquire(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	lease, err := pool.AcquireLease(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaks := pool.Leaks(0)
+	if len(leaks) != 1 {
+		t.Fatalf("leaks %+v is wrong", leaks)
+	}
+
+	if leaks[0].Stack != "" {
+		t.Fatalf("stack %s is wrong", leaks[0].Stack)
+	}
+
+	if leaks := pool.Leaks(time.Minute); len(leaks) != 0 {
+		t.Fatalf("leaks %+v is wrong", leaks)
+	}
+
+	lease.Release(ctx)
+
+	if leaks := pool.Leaks(0); len(leaks) != 0 {
+		t.Fatalf("leaks %+v is wrong", leaks)
+	}
+}
+
+// go test -v -cover -run=^TestPoolLeakDetection$
+func TestPoolLeakDetection(t *testing.T) {
+	ctx := context.Background()
+
+	acquire := func(context.Context) (int, error) { return 1, nil }
+	release := func(context.Context, int) error { return nil }
+
+	var leaks []Leak[int]
+	var leaksLock sync.Mutex
+	onLeak := func(leak Leak[int]) {
+		leaksLock.Lock()
+		leaks = append(leaks, leak)
+		leaksLock.Unlock()
+	}
+
+	pool := New(4, acquire, release).WithLeakDetection(20*time.Millisecond, onLeak)
+	defer pool.Close(ctx)
+
+	resource, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lease, err := pool.AcquireLease(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer lease.Release(ctx)
+
+	time.Sleep(5 * time.Millisecond)
+	pool.Release(ctx, resource)
+
+	// Each leak should be reported only once.
+	time.Sleep(60 * time.Millisecond)
+
+	leaksLock.Lock()
+	defer leaksLock.Unlock()
+
+	if len(leaks) != 1 {
+		t.Fatalf("leaks %+v is wrong", leaks)
+	}
+
+	leak := leaks[0]
+	if leak.Resource != 1 || !leak.AcquiredAt.Equal(lease.AcquiredAt()) || leak.Duration < 20*time.Millisecond {
+		t.Fatalf("leak %+v is wrong", leak)
+	}
+
+	if !strings.Contains(leak.Stack, "TestPoolLeakDetection") {
+		t.Fatalf("stack %s is wrong", leak.Stack)
+	}
+}
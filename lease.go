@@ -0,0 +1,266 @@
+// Copyright 2025 FishGoddess. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package rego
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// ticket is used to find the entry of a lease dropped without releasing.
+// It shouldn't reference the lease or the lease will never be unreachable.
+type ticket[Resource any] struct {
+	entry    *entry[Resource]
+	useCount uint64
+}
+
+// Lease is a resource acquired from pool and you should release or discard it after using.
+// Releasing or discarding a lease more than once is safe and only the first one works.
+type Lease[Resource any] struct {
+	pool       *Pool[Resource]
+	entry      *entry[Resource]
+	resource   Resource
+	createdAt  time.Time
+	acquiredAt time.Time
+	useCount   uint64
+	ctx        context.Context
+	cancel     context.CancelCauseFunc
+	timer      *time.Timer
+	cleanup    runtime.Cleanup
+	done       atomic.Bool
+}
+
+// WithLeaseRecovery recovers the leases dropped without releasing when they are garbage collected.
+// The capacity of dropped leases will be reclaimed, and the onDropped function will be called for each dropped lease
+// if it's not nil. The resources of dropped leases will be released if release is true, otherwise they will be left
+// to the callers because they may be still in use.
+func (p *Pool[Resource]) WithLeaseRecovery(onDropped LeakFunc[Resource], release bool) *Pool[Resource] {
+	p.lock.Lock()
+	p.recovery = true
+	p.onDropped = onDropped
+	p.dropRelease = release
+	p.lock.Unlock()
+
+	return p
+}
+
+// WithLeaseTTL sets the max duration of holding a lease, and the context of lease will be canceled with
+// ErrLeaseExpired after ttl. The onExpired function will be called for each expired lease if it's not nil.
+// The capacity of expired leases will be reclaimed if reclaim is true, so the waiters won't be starved by them.
+// The resources of reclaimed leases will be released when the leases are released or discarded.
+// A ttl <= 0 means leases can be held forever.
+func (p *Pool[Resource]) WithLeaseTTL(ttl time.Duration, onExpired LeakFunc[Resource], reclaim bool) *Pool[Resource] {
+	p.lock.Lock()
+	p.leaseTTL = ttl
+	p.onExpired = onExpired
+	p.reclaim = reclaim
+	p.lock.Unlock()
+
+	return p
+}
+
+// leased returns true if the entry is still borrowed by the lease of ticket.
+// It should be called with lock held.
+func (p *Pool[Resource]) leased(ticket ticket[Resource]) bool {
+	_, ok := p.borrowed[ticket.entry]
+	return ok && ticket.entry.useCount == ticket.useCount
+}
+
+// expireLease cancels the context of a lease held longer than its ttl and reclaims its capacity if need.
+func (p *Pool[Resource]) expireLease(ticket ticket[Resource]) {
+	entry := ticket.entry
+
+	p.lock.Lock()
+	if !p.leased(ticket) {
+		p.lock.Unlock()
+		return
+	}
+
+	entry.cancel(ErrLeaseExpired)
+
+	if p.reclaim {
+		delete(p.borrowed, entry)
+		p.forget(entry)
+		p.freeActive(1)
+	}
+
+	// The entry may be released and acquired again after unlocking, so we build the leak here.
+	leak := leakOf(entry, time.Now())
+	onExpired := p.onExpired
+	p.lock.Unlock()
+
+	if onExpired != nil {
+		notify(onExpired, leak)
+	}
+}
+
+// recoverLease reclaims the capacity of a lease dropped without releasing.
+func (p *Pool[Resource]) recoverLease(ticket ticket[Resource]) {
+	ctx := context.Background()
+	entry := ticket.entry
+
+	p.lock.Lock()
+	if !p.leased(ticket) {
+		p.lock.Unlock()
+		return
+	}
+
+	release := p.dropRelease
+	if release {
+		p.pendRelease(1)
+	}
+
+	delete(p.borrowed, entry)
+	p.forget(entry)
+	p.freeActive(1)
+
+	leak := leakOf(entry, time.Now())
+	onDropped := p.onDropped
+	p.lock.Unlock()
+
+	if onDropped != nil {
+		notify(onDropped, leak)
+	}
+
+	if release {
+		// There is nothing we can do with the errors returned in background.
+		p.releasePending(ctx, leak.Resource)
+	}
+}
+
+// AcquireLease acquires a lease of resource from pool and returns an error if failed.
+// You should call Lease.Release or Lease.Discard to return the resource back to the pool.
+func (p *Pool[Resource]) AcquireLease(ctx context.Context) (*Lease[Resource], error) {
+	entry, err := p.acquireEntry(ctx, 0, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// The context of lease keeps the values of ctx but won't be canceled with ctx.
+	leaseCtx, cancel := context.WithCancelCause(context.WithoutCancel(ctx))
+
+	p.lock.Lock()
+	p.borrow(entry)
+	entry.cancel = cancel
+	recovery := p.recovery
+	ttl := p.leaseTTL
+	p.lock.Unlock()
+
+	lease := &Lease[Resource]{
+		pool:       p,
+		entry:      entry,
+		resource:   entry.resource,
+		createdAt:  entry.createdAt,
+		acquiredAt: entry.acquiredAt,
+		useCount:   entry.useCount,
+		ctx:        leaseCtx,
+		cancel:     cancel,
+	}
+
+	ticket := ticket[Resource]{entry: entry, useCount: entry.useCount}
+	if ttl > 0 {
+		lease.timer = time.AfterFunc(ttl, func() {
+			p.expireLease(ticket)
+		})
+	}
+
+	if recovery {
+		lease.cleanup = runtime.AddCleanup(lease, p.recoverLease, ticket)
+	}
+
+	return lease, nil
+}
+
+// Value returns the resource of lease.
+func (l *Lease[Resource]) Value() Resource {
+	return l.resource
+}
+
+// CreatedAt returns the time when the resource was created.
+func (l *Lease[Resource]) CreatedAt() time.Time {
+	return l.createdAt
+}
+
+// AcquiredAt returns the time when the lease was acquired.
+func (l *Lease[Resource]) AcquiredAt() time.Time {
+	return l.acquiredAt
+}
+
+// UseCount returns the times that the resource was acquired including this lease.
+func (l *Lease[Resource]) UseCount() uint64 {
+	return l.useCount
+}
+
+// Context returns the context of lease which will be canceled when the lease is released, discarded or expired.
+// Use context.Cause to know why the context is canceled.
+func (l *Lease[Resource]) Context() context.Context {
+	return l.ctx
+}
+
+// finish finishes the lease and returns false if the lease has been finished.
+func (l *Lease[Resource]) finish() bool {
+	if !l.done.CompareAndSwap(false, true) {
+		return false
+	}
+
+	if l.timer != nil {
+		l.timer.Stop()
+	}
+
+	l.cleanup.Stop()
+	l.cancel(nil)
+	return true
+}
+
+// Release releases the resource to pool so we can reuse it next time.
+func (l *Lease[Resource]) Release(ctx context.Context) error {
+	if !l.finish() {
+		return nil
+	}
+
+	return l.pool.returnLease(ctx, l.entry, false)
+}
+
+// Discard releases the resource without putting it back to pool.
+// You should discard the resource if it's broken.
+func (l *Lease[Resource]) Discard(ctx context.Context) error {
+	if !l.finish() {
+		return nil
+	}
+
+	return l.pool.returnLease(ctx, l.entry, true)
+}
+
+// returnLease returns the entry of lease to pool, and the entry will be released directly if it's reclaimed.
+func (p *Pool[Resource]) returnLease(ctx context.Context, entry *entry[Resource], discard bool) error {
+	p.lock.Lock()
+
+	// The capacity of lease has been reclaimed, so we release the resource without updating the active.
+	if _, ok := p.borrowed[entry]; !ok {
+		p.pendRelease(1)
+		p.lock.Unlock()
+
+		return p.releasePending(ctx, entry.resource)
+	}
+
+	delete(p.borrowed, entry)
+
+	reused := false
+	if discard {
+		p.drop(entry)
+	} else {
+		reused = p.reuse(entry)
+	}
+
+	p.lock.Unlock()
+
+	if reused {
+		return nil
+	}
+
+	return p.releasePending(ctx, entry.resource)
+}
@@ -0,0 +1,388 @@
+// Copyright 2025 FishGoddess. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package rego
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// go test -v -cover -run=^TestLease$
+func TestLease(t *testing.T) {
+	ctx := context.Background()
+
+	var acquired int64
+	var released int64
+	acquire := func(context.Context) (int64, error) { return atomic.AddInt64(&acquired, 1), nil }
+	release := func(context.Context, int64) error {
+		atomic.AddInt64(&released, 1)
+		return nil
+	}
+
+	pool := New(2, acquire, release)
+	defer pool.Close(ctx)
+
+	beginTime := time.Now()
+
+	lease, err := pool.AcquireLease(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lease.Value() != 1 {
+		t.Fatalf("got %d != want %d", lease.Value(), 1)
+	}
+
+	if lease.UseCount() != 1 {
+		t.Fatalf("got %d != want %d", lease.UseCount(), 1)
+	}
+
+	if lease.CreatedAt().Before(beginTime) || lease.AcquiredAt().Before(lease.CreatedAt()) {
+		t.Fatalf("created at %v or acquired at %v is wrong", lease.CreatedAt(), lease.AcquiredAt())
+	}
+
+	if status := pool.Status(); status.Using != 1 {
+		t.Fatalf("using %d is wrong", status.Using)
+	}
+
+	// Releasing twice should be safe.
+	for range 2 {
+		if err = lease.Release(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		if status := pool.Status(); status.Using != 0 || status.Idle != 1 {
+			t.Fatalf("status %+v is wrong", status)
+		}
+	}
+
+	createdAt := lease.CreatedAt()
+
+	lease, err = pool.AcquireLease(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lease.Value() != 1 {
+		t.Fatalf("got %d != want %d", lease.Value(), 1)
+	}
+
+	if lease.UseCount() != 2 {
+		t.Fatalf("got %d != want %d", lease.UseCount(), 2)
+	}
+
+	if !lease.CreatedAt().Equal(createdAt) {
+		t.Fatalf("got %v != want %v", lease.CreatedAt(), createdAt)
+	}
+
+	// Discarding twice or releasing after discarding should be safe.
+	for range 2 {
+		if err = lease.Discard(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = lease.Release(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		if status := pool.Status(); status.Using != 0 || status.Idle != 0 || status.Discarded != 1 {
+			t.Fatalf("status %+v is wrong", status)
+		}
+	}
+
+	if got := atomic.LoadInt64(&released); got != 1 {
+		t.Fatalf("released %d is wrong", got)
+	}
+
+	pool.Close(ctx)
+
+	if _, err = pool.AcquireLease(ctx); !errors.Is(err, ErrPoolClosed) {
+		t.Fatalf("got %+v != want %+v", err, ErrPoolClosed)
+	}
+}
+
+// go test -v -cover -run=^TestWithLeaseRecovery$
+func TestWithLeaseRecovery(t *testing.T) {
+	onDropped := func(Leak[int]) {}
+
+	pool := &Pool[int]{}
+	pool.WithLeaseRecovery(onDropped, true)
+
+	if !pool.recovery {
+		t.Fatalf("got %+v is wrong", pool.recovery)
+	}
+
+	if pool.onDropped == nil {
+		t.Fatal("pool.onDropped is nil")
+	}
+
+	if !pool.dropRelease {
+		t.Fatalf("got %+v is wrong", pool.dropRelease)
+	}
+}
+
+// go test -v -cover -run=^TestLeaseRecovery$
+func TestLeaseRecovery(t *testing.T) {
+	ctx := context.Background()
+
+	var acquired int64
+	var released int64
+	acquire := func(context.Context) (int64, error) { return atomic.AddInt64(&acquired, 1), nil }
+	release := func(context.Context, int64) error {
+		atomic.AddInt64(&released, 1)
+		return nil
+	}
+
+	dropped := make(chan Leak[int64], 1)
+	onDropped := func(leak Leak[int64]) {
+		dropped <- leak
+	}
+
+	pool := New(1, acquire, release).WithLeaseRecovery(onDropped, true)
+	defer pool.Close(ctx)
+
+	// The lease released shouldn't be recovered.
+	lease, err := pool.AcquireLease(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lease.Release(ctx)
+
+	// The lease dropped should be recovered.
+	func() {
+		lease, err := pool.AcquireLease(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if lease.Value() != 1 {
+			t.Fatalf("got %d != want %d", lease.Value(), 1)
+		}
+	}()
+
+	var leak Leak[int64]
+	for i := 0; ; i++ {
+		runtime.GC()
+
+		select {
+		case leak = <-dropped:
+		case <-time.After(10 * time.Millisecond):
+			if i < 100 {
+				continue
+			}
+
+			t.Fatal("lease not recovered")
+		}
+
+		break
+	}
+
+	if leak.Resource != 1 {
+		t.Fatalf("got %d != want %d", leak.Resource, 1)
+	}
+
+	// Wait for the resource released after reporting.
+	time.Sleep(10 * time.Millisecond)
+
+	if got := atomic.LoadInt64(&released); got != 1 {
+		t.Fatalf("released %d is wrong", got)
+	}
+
+	status := pool.Status()
+	if status.Using != 0 || status.Idle != 0 {
+		t.Fatalf("status %+v is wrong", status)
+	}
+
+	select {
+	case leak = <-dropped:
+		t.Fatalf("leak %+v shouldn't be reported", leak)
+	default:
+	}
+}
+
+// go test -v -cover -run=^TestWithLeaseTTL$
+func TestWithLeaseTTL(t *testing.T) {
+	onExpired := func(Leak[int]) {}
+
+	pool := &Pool[int]{}
+	pool.WithLeaseTTL(time.Minute, onExpired, true)
+
+	if pool.leaseTTL != time.Minute {
+		t.Fatalf("got %d != want %d", pool.leaseTTL, time.Minute)
+	}
+
+	if pool.onExpired == nil {
+		t.Fatal("pool.onExpired is nil")
+	}
+
+	if !pool.reclaim {
+		t.Fatalf("got %+v is wrong", pool.reclaim)
+	}
+}
+
+// go test -v -cover -run=^TestLeaseTTL$
+func TestLeaseTTL(t *testing.T) {
+	ctx := context.Background()
+
+	var acquired int64
+	var released int64
+	acquire := func(context.Context) (int64, error) { return atomic.AddInt64(&acquired, 1), nil }
+	release := func(context.Context, int64) error {
+		atomic.AddInt64(&released, 1)
+		return nil
+	}
+
+	expired := make(chan Leak[int64], 1)
+	onExpired := func(leak Leak[int64]) {
+		expired <- leak
+	}
+
+	pool := New(1, acquire, release).WithLeaseTTL(20*time.Millisecond, onExpired, true)
+	defer pool.Close(ctx)
+
+	lease, err := pool.AcquireLease(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaseCtx := lease.Context()
+	if leaseCtx.Err() != nil {
+		t.Fatalf("got %+v is wrong", leaseCtx.Err())
+	}
+
+	// The waiter shouldn't be starved by the expired lease.
+	waited, err := pool.AcquireLease(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if waited.Value() != 2 {
+		t.Fatalf("got %d != want %d", waited.Value(), 2)
+	}
+
+	select {
+	case <-leaseCtx.Done():
+	default:
+		t.Fatal("lease context not canceled")
+	}
+
+	if cause := context.Cause(leaseCtx); cause != ErrLeaseExpired {
+		t.Fatalf("got %+v != want %+v", cause, ErrLeaseExpired)
+	}
+
+	leak := <-expired
+	if leak.Resource != 1 || leak.Duration < 20*time.Millisecond {
+		t.Fatalf("leak %+v is wrong", leak)
+	}
+
+	// Releasing the expired lease should release the resource without updating the active.
+	if err = lease.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := atomic.LoadInt64(&released); got != 1 {
+		t.Fatalf("released %d is wrong", got)
+	}
+
+	status := pool.Status()
+	if status.Using != 1 || status.Idle != 0 {
+		t.Fatalf("status %+v is wrong", status)
+	}
+
+	// The lease released in time shouldn't be expired.
+	if err = waited.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if cause := context.Cause(waited.Context()); cause != context.Canceled {
+		t.Fatalf("got %+v != want %+v", cause, context.Canceled)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	select {
+	case leak = <-expired:
+		t.Fatalf("leak %+v shouldn't be expired", leak)
+	default:
+	}
+
+	status = pool.Status()
+	if status.Using != 0 || status.Idle != 1 {
+		t.Fatalf("status %+v is wrong", status)
+	}
+}
+
+// go test -v -cover -run=^TestLeaseTTLWithoutReclaim$
+func TestLeaseTTLWithoutReclaim(t *testing.T) {
+	ctx := context.Background()
+
+	acquire := func(context.Context) (int, error) { return 1, nil }
+	release := func(context.Context, int) error { return nil }
+
+	pool := New(1, acquire, release).WithLeaseTTL(10*time.Millisecond, nil, false)
+	defer pool.Close(ctx)
+
+	lease, err := pool.AcquireLease(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	<-lease.Context().Done()
+
+	status := pool.Status()
+	if status.Using != 1 {
+		t.Fatalf("status %+v is wrong", status)
+	}
+
+	lease.Release(ctx)
+
+	status = pool.Status()
+	if status.Using != 0 || status.Idle != 1 {
+		t.Fatalf("status %+v is wrong", status)
+	}
+}
+
+// go test -v -cover -race -run=^TestLeaseTTLReacquire$
+func TestLeaseTTLReacquire(t *testing.T) {
+	ctx := context.Background()
+
+	acquire := func(context.Context) (int, error) { return 1, nil }
+	release := func(context.Context, int) error { return nil }
+
+	var expired atomic.Int64
+	onExpired := func(leak Leak[int]) {
+		if leak.Resource != 1 {
+			t.Errorf("got %d != want %d", leak.Resource, 1)
+		}
+
+		expired.Add(1)
+	}
+
+	pool := New(1, acquire, release).WithLeaseTTL(50*time.Microsecond, onExpired, false)
+	defer pool.Close(ctx)
+
+	for range 100 {
+		lease, err := pool.AcquireLease(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		<-lease.Context().Done()
+
+		// The expired lease isn't reclaimed, so the resource will be reused by the next lease.
+		if err = lease.Release(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if expired.Load() <= 0 {
+		t.Fatalf("got %d is wrong", expired.Load())
+	}
+}
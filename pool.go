@@ -7,12 +7,22 @@ package rego
 import (
 	"context"
 	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
 	"sync"
 	"time"
 )
 
-var (
-	errPoolClosed = errors.New("rego: pool is closed")
+const (
+	// fillInterval is the interval of filling idle resources if the pool has a min idle.
+	fillInterval = time.Second
+
+	// priorityAging is the default aging of waiters, see Pool.WithPriorityAging.
+	priorityAging = time.Second
+
+	// maxForgotten is the max quantity of resources released by pool and remembered in strict mode.
+	maxForgotten = 1024
 )
 
 // AcquireFunc is a function acquires a new resource and returns error if failed.
@@ -24,21 +34,75 @@ type ReleaseFunc[Resource any] func(ctx context.Context, resource Resource) erro
 // AvailableFunc is a function checks if a resource is available.
 type AvailableFunc[Resource any] func(ctx context.Context, resource Resource) bool
 
+// KeepaliveFunc is a function keeps an idle resource alive and returns error if failed.
+type KeepaliveFunc[Resource any] func(ctx context.Context, resource Resource) error
+
+// IdentityFunc is a function returns the identity of a resource which must be comparable.
+type IdentityFunc[Resource any] func(resource Resource) any
+
 // PoolClosedErrFunc is a function returns a pool closed error.
 type PoolClosedErrFunc func(ctx context.Context) error
 
 // Pool stores some resources and you can reuse them.
 type Pool[Resource any] struct {
-	resources chan Resource
+	idle      []*entry[Resource]
+	order     IdleOrder
+	tracked   map[any][]*entry[Resource]
+	borrowed  map[*entry[Resource]]struct{}
+	known     map[any]uint64
+	forgotten map[any]struct{}
+	forgets   []any
+	strict    bool
+	ready     chan struct{}
+	readyOnce sync.Once
+	drained   chan struct{}
 	closed    bool
 
 	acquire      AcquireFunc[Resource]
 	release      ReleaseFunc[Resource]
 	available    AvailableFunc[Resource]
+	keepalive    KeepaliveFunc[Resource]
+	identity     IdentityFunc[Resource]
+	fatal        FatalFunc
 	newClosedErr PoolClosedErrFunc
 
+	idleTimeout time.Duration
+	maxLifetime time.Duration
+	maxJitter   time.Duration
+	maxUses     uint64
+	minIdle     uint64
+	leakTime    time.Duration
+	onLeak      LeakFunc[Resource]
+	onDropped   LeakFunc[Resource]
+	recovery    bool
+	dropRelease bool
+	leaseTTL    time.Duration
+	onExpired   LeakFunc[Resource]
+	reclaim     bool
+	checkTime   time.Duration
+	aliveTime   time.Duration
+	reaper      *task
+	filler      *task
+	checker     *task
+	keeper      *task
+	detector    *task
+
 	limit          uint64
 	active         uint64
+	checking       uint64
+	releasing      uint64
+	discarded      uint64
+	waiters        waiters[Resource]
+	holder         *waiter[Resource]
+	seq            uint64
+	aging          time.Duration
+	fair           bool
+	maxWaiting     uint64
+	delayTarget    time.Duration
+	delayInterval  time.Duration
+	delayDeadline  time.Time
+	minDelay       time.Duration
+	congested      bool
 	waiting        uint64
 	waited         uint64
 	waitedDuration time.Duration
@@ -58,7 +122,8 @@ func New[Resource any](limit uint64, acquire AcquireFunc[Resource], release Rele
 	}
 
 	available := func(context.Context, Resource) bool { return true }
-	newClosedErr := func(context.Context) error { return errPoolClosed }
+	newClosedErr := func(context.Context) error { return ErrPoolClosed }
+	fatal := func(error) bool { return false }
 
 	pool := &Pool[Resource]{
 		limit:        limit,
@@ -66,7 +131,16 @@ func New[Resource any](limit uint64, acquire AcquireFunc[Resource], release Rele
 		release:      release,
 		available:    available,
 		newClosedErr: newClosedErr,
-		resources:    make(chan Resource, limit),
+		fatal:        fatal,
+		idle:         make([]*entry[Resource], 0, limit),
+		order:        IdleFIFO,
+		tracked:      make(map[any][]*entry[Resource]),
+		borrowed:     make(map[*entry[Resource]]struct{}),
+		known:        make(map[any]uint64),
+		forgotten:    make(map[any]struct{}),
+		ready:        make(chan struct{}),
+		drained:      make(chan struct{}),
+		aging:        priorityAging,
 		closed:       false,
 	}
 
@@ -93,124 +167,849 @@ func (p *Pool[Resource]) WithPoolClosedErrFunc(newClosedErr PoolClosedErrFunc) *
 	return p
 }
 
-func (p *Pool[Resource]) acquireIdle() (resource Resource, ok bool) {
+// WithIdentityFunc sets the identity function used to track resources.
+// Resources are tracked by themselves by default, so you need it if your resources aren't comparable.
+// Notice that the identity function should be set before acquiring any resources.
+func (p *Pool[Resource]) WithIdentityFunc(identity IdentityFunc[Resource]) *Pool[Resource] {
+	if identity != nil {
+		p.lock.Lock()
+		p.identity = identity
+		p.lock.Unlock()
+	}
+
+	return p
+}
+
+// WithStrictRelease checks the resources released or discarded in strict mode.
+// A resource released more than once will be rejected with ErrDoubleRelease, and a resource not acquired from pool
+// will be rejected with ErrForeignResource. The resources rejected won't be released by release function.
+// The latest resources released by pool are remembered, so releasing them again is also rejected with ErrDoubleRelease.
+// Notice that resources which can't be tracked won't be checked, see Pool.WithIdentityFunc.
+func (p *Pool[Resource]) WithStrictRelease(strict bool) *Pool[Resource] {
+	p.lock.Lock()
+	p.strict = strict
+	p.lock.Unlock()
+
+	return p
+}
+
+// WithIdleTimeout sets the idle timeout of resources and a reaper will release the idle resources periodically.
+// Resources idle longer than timeout won't be reused, and a timeout <= 0 means no idle timeout.
+// Notice that resources which aren't comparable are reused as new ones after released, so the reaper only checks
+// their idle time and never retires them by lifetime or uses, see Pool.WithMaxLifetime.
+func (p *Pool[Resource]) WithIdleTimeout(timeout time.Duration) *Pool[Resource] {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.idleTimeout = timeout
+	p.resetReaper()
+	return p
+}
+
+// WithMaxLifetime sets the max lifetime of resources and resources live longer than it will be retired.
+// A random duration in [0, jitter) will be subtracted from each resource's lifetime, so resources acquired at the
+// same time won't be retired at the same time. A lifetime <= 0 means resources can live forever.
+// Notice that resources which aren't comparable can't be tracked, so their lifetime restarts every time they are
+// released and they will NEVER be retired if they are reused in time. Use Pool.WithIdentityFunc to track them, or use
+// Pool.AcquireLease which keeps the resource with its lease.
+func (p *Pool[Resource]) WithMaxLifetime(lifetime time.Duration, jitter time.Duration) *Pool[Resource] {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.maxLifetime = lifetime
+	p.maxJitter = min(max(jitter, 0), max(lifetime, 0))
+	p.resetReaper()
+	return p
+}
+
+// WithMaxUses sets the max uses of resources and resources acquired more than it will be retired.
+// A uses == 0 means resources can be used without limit.
+// Notice that resources which aren't comparable can't be tracked, so their uses are counted from zero every time.
+// Use Pool.WithIdentityFunc to track them, or use Pool.AcquireLease which keeps the resource with its lease.
+func (p *Pool[Resource]) WithMaxUses(uses uint64) *Pool[Resource] {
+	p.lock.Lock()
+	p.maxUses = uses
+	p.lock.Unlock()
+
+	return p
+}
+
+// WithMinIdle sets the min idle of pool and a filler will acquire new resources to keep min idle resources ready.
+// The filler runs in background after resources are acquired or discarded, and also runs periodically in case of
+// acquiring failed. A min idle == 0 means the pool won't acquire resources in advance.
+func (p *Pool[Resource]) WithMinIdle(minIdle uint64) *Pool[Resource] {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.filler.stop()
+	p.filler = nil
+
+	p.minIdle = min(minIdle, p.limit)
+	if p.minIdle <= 0 || p.closed {
+		return p
+	}
+
+	p.filler = newTask(fillInterval, p.fillIdle)
+	p.filler.wake()
+
+	go p.filler.run()
+	return p
+}
+
+// WithHealthCheck checks the idle resources by available function periodically and releases the unavailable ones.
+// Each resource is taken out of the pool while checking, and the check will be canceled after timeout.
+// An interval <= 0 means no health check, and a timeout <= 0 means checking without timeout.
+func (p *Pool[Resource]) WithHealthCheck(interval time.Duration, timeout time.Duration) *Pool[Resource] {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.checker.stop()
+	p.checker = nil
+	p.checkTime = timeout
+
+	if interval <= 0 || p.closed {
+		return p
+	}
+
+	p.checker = newTask(interval, p.checkIdle)
+	go p.checker.run()
+	return p
+}
+
+// WithKeepalive keeps the idle resources alive by keepalive function periodically and releases the failed ones.
+// Only the resources which have been inactive for at least an interval will be kept alive, and the keepalive will be
+// canceled after an interval. An interval <= 0 or a nil keepalive function means no keepalive.
+func (p *Pool[Resource]) WithKeepalive(interval time.Duration, keepalive KeepaliveFunc[Resource]) *Pool[Resource] {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.keeper.stop()
+	p.keeper = nil
+	p.aliveTime = interval
+	p.keepalive = keepalive
+
+	if interval <= 0 || keepalive == nil || p.closed {
+		return p
+	}
+
+	p.keeper = newTask(interval, p.keepaliveIdle)
+	go p.keeper.run()
+	return p
+}
+
+// stopTasks stops all tasks running in background.
+// It should be called with lock held.
+func (p *Pool[Resource]) stopTasks() {
+	p.reaper.stop()
+	p.reaper = nil
+
+	p.filler.stop()
+	p.filler = nil
+
+	p.checker.stop()
+	p.checker = nil
+
+	p.keeper.stop()
+	p.keeper = nil
+
+	p.detector.stop()
+	p.detector = nil
+}
+
+// resetReaper stops the reaper and runs a new one if need.
+// It should be called with lock held.
+func (p *Pool[Resource]) resetReaper() {
+	p.reaper.stop()
+	p.reaper = nil
+
+	if p.closed {
+		return
+	}
+
+	timeout := p.idleTimeout
+	if timeout <= 0 || (p.maxLifetime > 0 && p.maxLifetime < timeout) {
+		timeout = p.maxLifetime
+	}
+
+	if timeout <= 0 {
+		return
+	}
+
+	// Reaping at half of the timeout so the idle resources won't live too long after timeout.
+	interval := timeout / 2
+	if interval <= 0 {
+		interval = timeout
+	}
+
+	p.reaper = newTask(interval, p.reapIdle)
+	go p.reaper.run()
+}
+
+// newEntry returns a new entry of resource.
+// It should be called with lock held.
+func (p *Pool[Resource]) newEntry(resource Resource) *entry[Resource] {
+	entry := &entry[Resource]{
+		resource:  resource,
+		createdAt: time.Now(),
+	}
+
+	if p.maxJitter > 0 {
+		entry.jitter = rand.N(p.maxJitter)
+	}
+
+	entry.key, entry.keyed = keyOf(resource, p.identity)
+	if entry.keyed {
+		p.known[entry.key]++
+	}
+
+	return entry
+}
+
+// forget forgets the entry because its resource will be released.
+// It should be called with lock held.
+func (p *Pool[Resource]) forget(entry *entry[Resource]) {
+	if !entry.keyed {
+		return
+	}
+
+	if p.known[entry.key] > 1 {
+		p.known[entry.key]--
+		return
+	}
+
+	delete(p.known, entry.key)
+
+	if p.strict {
+		p.remember(entry.key)
+	}
+}
+
+// remember remembers the key of resource released by pool, so releasing it again can be told from foreign resources.
+// Only the latest max forgotten keys are remembered.
+// It should be called with lock held.
+func (p *Pool[Resource]) remember(key any) {
+	if _, ok := p.forgotten[key]; ok {
+		return
+	}
+
+	if len(p.forgets) >= maxForgotten {
+		delete(p.forgotten, p.forgets[0])
+		p.forgets[0] = nil
+		p.forgets = p.forgets[1:]
+	}
+
+	p.forgotten[key] = struct{}{}
+	p.forgets = append(p.forgets, key)
+}
+
+// track tracks the entry acquired by caller so we can find it when it's released.
+// It should be called with lock held.
+func (p *Pool[Resource]) track(entry *entry[Resource]) {
+	if entry.keyed {
+		p.tracked[entry.key] = append(p.tracked[entry.key], entry)
+		p.borrow(entry)
+	}
+}
+
+// untrack finds the entry of resource released by caller and stops tracking it.
+// A new entry will be returned if the resource isn't tracked, so its lifetime and uses start over.
+// It should be called with lock held.
+func (p *Pool[Resource]) untrack(resource Resource) *entry[Resource] {
+	key, ok := keyOf(resource, p.identity)
+	if !ok {
+		return p.newEntry(resource)
+	}
+
+	entries := p.tracked[key]
+	if len(entries) <= 0 {
+		return p.newEntry(resource)
+	}
+
+	last := len(entries) - 1
+	entry := entries[last]
+	entries[last] = nil
+
+	if last > 0 {
+		p.tracked[key] = entries[:last]
+	} else {
+		delete(p.tracked, key)
+	}
+
+	delete(p.borrowed, entry)
+	return entry
+}
+
+// wakeFiller wakes up the filler so it can fill the idle resources.
+// It should be called with lock held.
+func (p *Pool[Resource]) wakeFiller() {
+	if p.filler != nil {
+		p.filler.wake()
+	}
+}
+
+// checkReturned checks if the resource returned by caller is acquired from pool in strict mode.
+// It should be called with lock held.
+func (p *Pool[Resource]) checkReturned(resource Resource) error {
+	if !p.strict {
+		return nil
+	}
+
+	key, ok := keyOf(resource, p.identity)
+	if !ok || len(p.tracked[key]) > 0 {
+		return nil
+	}
+
+	if p.known[key] > 0 {
+		return ErrDoubleRelease
+	}
+
+	if _, ok := p.forgotten[key]; ok {
+		return ErrDoubleRelease
+	}
+
+	return ErrForeignResource
+}
+
+// freeActive decreases the active and wakes up the waiters so they can acquire new resources.
+// It should be called with lock held.
+func (p *Pool[Resource]) freeActive(n uint64) {
+	// The active may be freed twice if some resources returned aren't acquired from pool, so we check it to avoid overflow.
+	p.active -= min(p.active, n)
+	p.grantActive()
+	p.wakeFiller()
+	p.markDrained()
+}
+
+// markDrained marks the pool drained if it's closed and all resources are returned and released.
+// It should be called with lock held.
+func (p *Pool[Resource]) markDrained() {
+	if !p.closed || p.active > 0 || p.releasing > 0 {
+		return
+	}
+
 	select {
-	case resource := <-p.resources:
-		return resource, true
+	case <-p.drained:
 	default:
-		return resource, false
+		close(p.drained)
 	}
 }
 
-func (p *Pool[Resource]) waitIdle(ctx context.Context) (resource Resource, err error) {
-	select {
-	case resource := <-p.resources:
-		return resource, nil
-	case <-ctx.Done():
-		return resource, ctx.Err()
+// pendRelease marks n resources pending release, so the pool won't be drained until they are released.
+// It should be called with lock held before freeing their active, and each resource should be released by releasePending.
+func (p *Pool[Resource]) pendRelease(n uint64) {
+	p.releasing += n
+}
+
+// releasePending releases the resource pending release and marks the pool drained if it's the last one.
+func (p *Pool[Resource]) releasePending(ctx context.Context, resource Resource) error {
+	err := p.releaseResource(ctx, resource)
+
+	p.lock.Lock()
+	p.releasing -= min(p.releasing, 1)
+	p.markDrained()
+	p.lock.Unlock()
+
+	return err
+}
+
+// fillIdle acquires new resources until the idle resources reach the min idle or the active reaches the limit.
+func (p *Pool[Resource]) fillIdle(ctx context.Context) {
+	for {
+		p.lock.Lock()
+		if p.closed || uint64(len(p.idle)) >= p.minIdle || p.active >= p.limit {
+			p.lock.Unlock()
+			return
+		}
+
+		p.active++
+		p.lock.Unlock()
+
+		resource, err := p.acquireResource(ctx)
+
+		p.lock.Lock()
+		if err != nil {
+			// Don't wake up the filler again or it will keep acquiring until the next tick.
+			p.active -= min(p.active, 1)
+			p.grantActive()
+			p.markDrained()
+			p.lock.Unlock()
+			return
+		}
+
+		entry := p.newEntry(resource)
+		reused := p.reuse(entry)
+		p.lock.Unlock()
+
+		if !reused {
+			p.releasePending(ctx, resource)
+			return
+		}
 	}
 }
 
-// Acquire acquires a resource from pool and returns an error if failed.
-// You should call Pool.Release to return the resource back to the pool.
-func (p *Pool[Resource]) Acquire(ctx context.Context) (resource Resource, err error) {
+func (p *Pool[Resource]) idleTimedOut(entry *entry[Resource], now time.Time) bool {
+	return p.idleTimeout > 0 && now.Sub(entry.idleAt) > p.idleTimeout
+}
+
+func (p *Pool[Resource]) retired(entry *entry[Resource], now time.Time) bool {
+	if p.maxUses > 0 && entry.useCount >= p.maxUses {
+		return true
+	}
+
+	return p.maxLifetime > 0 && now.Sub(entry.createdAt) >= p.maxLifetime-entry.jitter
+}
+
+// reapIdle releases the idle resources which are timed out or retired.
+func (p *Pool[Resource]) reapIdle(ctx context.Context) {
+	p.lock.Lock()
+	if p.closed {
+		p.lock.Unlock()
+		return
+	}
+
+	now := time.Now()
+
+	var reaped []Resource
+	p.idle = slices.DeleteFunc(p.idle, func(entry *entry[Resource]) bool {
+		if p.idleTimedOut(entry, now) || p.retired(entry, now) {
+			p.forget(entry)
+			reaped = append(reaped, entry.resource)
+			return true
+		}
+
+		return false
+	})
+
+	p.pendRelease(uint64(len(reaped)))
+	p.freeActive(uint64(len(reaped)))
+	p.lock.Unlock()
+
+	// There is nothing we can do with the errors returned by the background reaper.
+	for _, resource := range reaped {
+		p.releasePending(ctx, resource)
+	}
+}
+
+// restore puts the entry back to pool without updating its idle time and returns false if the pool is closed.
+// It should be called with lock held.
+func (p *Pool[Resource]) restore(entry *entry[Resource]) bool {
+	if p.closed {
+		return false
+	}
+
+	// The entry is taken from the pool so there is always a room for it, and it keeps its order of idle time.
+	if !p.handOver(entry) {
+		p.insertIdle(entry)
+	}
+
+	return true
+}
+
+// putIdle hands the entry to a waiter or puts it back to pool, and returns false if the pool is full.
+// It should be called with lock held.
+func (p *Pool[Resource]) putIdle(entry *entry[Resource]) bool {
+	if p.handOver(entry) {
+		return true
+	}
+
+	return p.pushIdle(entry)
+}
+
+// visitIdle takes the idle resources out one by one and visits them.
+// The resource will be put back to pool if visit returns true, otherwise it will be discarded.
+func (p *Pool[Resource]) visitIdle(ctx context.Context, visit func(ctx context.Context, entry *entry[Resource]) bool) {
+	p.lock.RLock()
+	entries := slices.Clone(p.idle)
+	p.lock.RUnlock()
+
+	for _, entry := range entries {
+		// The entry may be acquired by others, so we skip it if it isn't idle now.
+		p.lock.Lock()
+		if !p.takeIdle(entry) {
+			p.lock.Unlock()
+			continue
+		}
+
+		p.checking++
+		p.lock.Unlock()
+
+		ok := visit(ctx, entry)
+
+		p.lock.Lock()
+		p.checking--
+
+		if ok && p.restore(entry) {
+			p.lock.Unlock()
+			continue
+		}
+
+		p.forget(entry)
+		p.pendRelease(1)
+		p.freeActive(1)
+
+		if !p.closed {
+			p.discarded++
+		}
+
+		p.lock.Unlock()
+
+		// There is nothing we can do with the errors returned in background.
+		p.releasePending(ctx, entry.resource)
+	}
+}
+
+// checkIdle checks the idle resources by available function and releases the unavailable ones.
+func (p *Pool[Resource]) checkIdle(ctx context.Context) {
+	p.lock.RLock()
+	timeout := p.checkTime
+	p.lock.RUnlock()
+
+	p.visitIdle(ctx, func(ctx context.Context, entry *entry[Resource]) bool {
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		available, _ := p.availableResource(ctx, entry.resource)
+		return available
+	})
+}
+
+// keepaliveIdle keeps the idle resources alive by keepalive function and releases the failed ones.
+// Only the resources which have been inactive for at least an interval will be kept alive.
+func (p *Pool[Resource]) keepaliveIdle(ctx context.Context) {
+	p.lock.RLock()
+	interval := p.aliveTime
+	keepalive := p.keepalive
+	p.lock.RUnlock()
+
+	p.visitIdle(ctx, func(ctx context.Context, entry *entry[Resource]) bool {
+		now := time.Now()
+		if now.Sub(entry.idleAt) < interval || now.Sub(entry.aliveAt) < interval {
+			return true
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, interval)
+		defer cancel()
+
+		if err := p.keepaliveResource(ctx, keepalive, entry.resource); err != nil {
+			return false
+		}
+
+		entry.aliveAt = time.Now()
+		return true
+	})
+}
+
+// checkEntry checks if the idle entry can be reused and releases it if not.
+// A nil entry and a nil error will be returned if the entry is released, so we should try again.
+// The entry will also be released if the available function panics, and the panic will be returned as an error.
+func (p *Pool[Resource]) checkEntry(ctx context.Context, entry *entry[Resource], stale bool) (*entry[Resource], error) {
+	var available bool
+	var err error
+	if !stale {
+		available, err = p.availableResource(ctx, entry.resource)
+	}
+
+	if available {
+		entry.use()
+		return entry, nil
+	}
+
+	p.lock.Lock()
+	if !stale {
+		p.discarded++
+	}
+
+	p.forget(entry)
+	p.pendRelease(1)
+	p.freeActive(1)
+	p.lock.Unlock()
+
+	releaseErr := p.releasePending(ctx, entry.resource)
+	if err == nil {
+		return nil, releaseErr
+	}
+
+	if releaseErr != nil {
+		err = errors.Join(err, releaseErr)
+	}
+
+	return nil, err
+}
+
+// createEntry acquires a new resource for the active reserved by caller.
+func (p *Pool[Resource]) createEntry(ctx context.Context) (*entry[Resource], error) {
+	resource, err := p.acquireResource(ctx)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if err != nil {
+		p.freeActive(1)
+		return nil, err
+	}
+
+	entry := p.newEntry(resource)
+	entry.use()
+	return entry, nil
+}
+
+// acquireEntry acquires an entry from pool and it waits for a idle one if the pool is exhausted and wait is true.
+// ErrPoolExhausted will be returned if the pool is exhausted and wait is false.
+// The waiters with higher priority will be granted first, see Pool.WithPriorityAging.
+func (p *Pool[Resource]) acquireEntry(ctx context.Context, priority int, wait bool) (*entry[Resource], error) {
+	var waited time.Duration
+	var discarded uint64
+
+	fail := func(err error) (*entry[Resource], error) {
+		return nil, &AcquireError{Err: err, Waited: waited, Discarded: discarded}
+	}
+
 	for {
 		p.lock.Lock()
 		if p.closed {
 			p.lock.Unlock()
 
-			err = p.newClosedErr(ctx)
-			return resource, err
+			err := p.closedErr(ctx)
+			return fail(err)
 		}
 
+		// New callers should queue behind the waiters in fair mode.
+		queued := p.queued()
+
 		// Try to acquire a idle resource from pool.
-		var ok bool
-		if resource, ok = p.acquireIdle(); ok {
+		if entry, ok := p.acquireIdle(); ok && queued {
+			// The entry is taken from the pool so there is always a room for it.
+			p.putIdle(entry)
+		} else if ok {
+			now := time.Now()
+			stale := p.idleTimedOut(entry, now) || p.retired(entry, now)
+			p.observeDelay(waited, now)
+			p.wakeFiller()
 			p.lock.Unlock()
 
-			if p.available(ctx, resource) {
-				return resource, nil
+			entry, err := p.checkEntry(ctx, entry, stale)
+			if err != nil {
+				return fail(fmt.Errorf("%w: %w", ErrResourceUnavailable, err))
 			}
 
-			p.lock.Lock()
-			p.active--
-			p.lock.Unlock()
-
-			if err = p.release(ctx, resource); err != nil {
-				return resource, err
+			if entry != nil {
+				return entry, nil
 			}
 
+			discarded++
 			continue
 		}
 
 		// No idle resource, we should acquire a new one or wait a idle one.
 		// Increase the active and unlock here may cause the pool becomes exhausted in advance.
 		// However, we think this is acceptable in most situations.
-		if p.active < p.limit {
+		if p.active < p.limit && !queued {
 			p.active++
+			p.observeDelay(waited, time.Now())
 			p.lock.Unlock()
 
-			resource, err = p.acquire(ctx)
+			entry, err := p.createEntry(ctx)
 			if err != nil {
-				p.lock.Lock()
-				p.active--
-				p.lock.Unlock()
+				return fail(err)
 			}
 
-			return resource, err
+			return entry, nil
+		}
+
+		if !wait || p.overloaded() {
+			p.lock.Unlock()
+			return fail(ErrPoolExhausted)
 		}
 
-		p.waiting++
+		w := p.enqueue(priority, 1)
+		waitCtx, cancel := p.waitContext(ctx)
 		p.lock.Unlock()
 
 		startTime := time.Now()
-		resource, err = p.waitIdle(ctx)
+		entry, granted, err := p.waitEntry(waitCtx, w)
 		endTime := time.Now()
+		waited += endTime.Sub(startTime)
+		cancel()
+
+		// The waiting times out fast since the pool is congested.
+		if err != nil && ctx.Err() == nil {
+			err = ErrPoolExhausted
+		}
 
 		p.lock.Lock()
 		p.waiting--
 		p.waited++
 		p.waitedDuration += endTime.Sub(startTime)
+		p.observeDelay(waited, endTime)
+		stale := entry != nil && (p.idleTimedOut(entry, endTime) || p.retired(entry, endTime))
+		if entry != nil {
+			p.wakeFiller()
+		}
+
 		p.lock.Unlock()
 
+		if errors.Is(err, context.DeadlineExceeded) {
+			return fail(fmt.Errorf("%w: %w", ErrAcquireTimeout, err))
+		}
+
 		if err != nil {
-			return resource, err
+			return fail(err)
 		}
 
-		if p.available(ctx, resource) {
-			return resource, nil
+		// The pool is closed, so we should try again.
+		if !granted {
+			continue
 		}
 
-		p.lock.Lock()
-		p.active--
-		p.lock.Unlock()
+		// An active is reserved for us, so we should acquire a new resource.
+		if entry == nil {
+			entry, err = p.createEntry(ctx)
+			if err != nil {
+				return fail(err)
+			}
+
+			return entry, nil
+		}
 
-		if err = p.release(ctx, resource); err != nil {
-			return resource, err
+		entry, err = p.checkEntry(ctx, entry, stale)
+		if err != nil {
+			return fail(fmt.Errorf("%w: %w", ErrResourceUnavailable, err))
+		}
+
+		if entry != nil {
+			return entry, nil
 		}
+
+		discarded++
 	}
 }
 
-// Release releases a resource to pool so we can reuse it next time.
-func (p *Pool[Resource]) Release(ctx context.Context, resource Resource) error {
+// Acquire acquires a resource from pool and returns an error if failed.
+// You should call Pool.Release to return the resource back to the pool.
+func (p *Pool[Resource]) Acquire(ctx context.Context) (resource Resource, err error) {
+	return p.AcquireWithPriority(ctx, 0)
+}
+
+// AcquireWithPriority acquires a resource from pool with priority and returns an error if failed.
+// The waiters with higher priority will be granted first if the pool is exhausted, see Pool.WithPriorityAging.
+// Any int is a valid priority, but the priorities beyond ±(math.MaxInt64/2)/aging are treated as the bound if aging is set.
+// You should call Pool.Release to return the resource back to the pool.
+func (p *Pool[Resource]) AcquireWithPriority(ctx context.Context, priority int) (resource Resource, err error) {
+	entry, err := p.acquireEntry(ctx, priority, true)
+	if err != nil {
+		return resource, err
+	}
+
 	p.lock.Lock()
+	p.track(entry)
+	p.lock.Unlock()
+
+	return entry.resource, nil
+}
+
+// TryAcquire acquires a resource from pool without waiting and returns ErrPoolExhausted if the pool is exhausted.
+// You should call Pool.Release to return the resource back to the pool.
+func (p *Pool[Resource]) TryAcquire(ctx context.Context) (resource Resource, err error) {
+	entry, err := p.acquireEntry(ctx, 0, false)
+	if err != nil {
+		return resource, err
+	}
+
+	p.lock.Lock()
+	p.track(entry)
+	p.lock.Unlock()
+
+	return entry.resource, nil
+}
+
+// reuse puts the entry back to pool and returns false if the entry should be released instead.
+// The entry will be pending release if false is returned, so it should be released by releasePending.
+// It should be called with lock held.
+func (p *Pool[Resource]) reuse(entry *entry[Resource]) bool {
 	if p.closed {
-		p.lock.Unlock()
+		p.forget(entry)
+		p.pendRelease(1)
+
+		// The resource may not be acquired from pool, so we check the active to avoid overflow.
+		if p.active > 0 {
+			p.freeActive(1)
+		}
 
-		return p.release(ctx, resource)
+		return false
 	}
 
-	select {
-	case p.resources <- resource:
+	now := time.Now()
+	if p.retired(entry, now) {
+		p.forget(entry)
+		p.pendRelease(1)
+		p.freeActive(1)
+		return false
+	}
+
+	entry.idleAt = now
+
+	if p.putIdle(entry) {
+		return true
+	}
+
+	p.forget(entry)
+	p.pendRelease(1)
+	p.freeActive(1)
+	return false
+}
+
+// drop drops the entry without putting it back to pool, and it should be released by releasePending.
+// It should be called with lock held.
+func (p *Pool[Resource]) drop(entry *entry[Resource]) {
+	p.forget(entry)
+	p.pendRelease(1)
+
+	if !p.closed {
+		p.discarded++
+	}
+
+	// The resource may not be acquired from pool, so we check the active to avoid overflow.
+	if p.active > 0 {
+		p.freeActive(1)
+	}
+}
+
+// Release releases a resource to pool so we can reuse it next time.
+func (p *Pool[Resource]) Release(ctx context.Context, resource Resource) error {
+	p.lock.Lock()
+	if err := p.checkReturned(resource); err != nil {
 		p.lock.Unlock()
+		return err
+	}
 
+	entry := p.untrack(resource)
+	reused := p.reuse(entry)
+	p.lock.Unlock()
+
+	if reused {
 		return nil
-	default:
-		p.active--
-		p.lock.Unlock()
+	}
+
+	return p.releasePending(ctx, resource)
+}
 
-		return p.release(ctx, resource)
+// Discard releases a resource without putting it back to pool.
+// You should discard the resource instead of releasing it if it's broken.
+func (p *Pool[Resource]) Discard(ctx context.Context, resource Resource) error {
+	p.lock.Lock()
+	if err := p.checkReturned(resource); err != nil {
+		p.lock.Unlock()
+		return err
 	}
+
+	entry := p.untrack(resource)
+	p.drop(entry)
+	p.lock.Unlock()
+
+	return p.releasePending(ctx, resource)
 }
 
 // Status returns the statistics of the pool.
@@ -223,53 +1022,117 @@ func (p *Pool[Resource]) Status() Status {
 		waitDuration = p.waitedDuration / time.Duration(p.waited)
 	}
 
-	idle := uint64(len(p.resources))
+	idle := uint64(len(p.idle))
 
 	status := Status{
 		Limit:        p.limit,
-		Using:        p.active - idle,
+		Using:        p.active - idle - p.checking,
 		Idle:         idle,
 		Waiting:      p.waiting,
 		WaitDuration: waitDuration,
+		Discarded:    p.discarded,
 	}
 
 	return status
 }
 
-func (p *Pool[Resource]) releaseAll(ctx context.Context) error {
-	for {
-		select {
-		case resource := <-p.resources:
-			if err := p.release(ctx, resource); err != nil {
-				return err
-			}
+// releaseAll releases all entries pending release and joins the errors returned.
+// It stops releasing once the context is done, and the context error will be joined.
+func (p *Pool[Resource]) releaseAll(ctx context.Context, entries []*entry[Resource]) error {
+	var errs []error
+	for i, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			// The entries left won't be released, so the pool shouldn't wait for them.
+			p.lock.Lock()
+			p.releasing -= min(p.releasing, uint64(len(entries)-i))
+			p.markDrained()
+			p.lock.Unlock()
 
-			p.active--
-		default:
-			return nil
+			errs = append(errs, fmt.Errorf("rego: %d resources aren't released: %w", len(entries)-i, err))
+			break
+		}
+
+		if err := p.releasePending(ctx, entry.resource); err != nil {
+			errs = append(errs, err)
 		}
 	}
+
+	return errors.Join(errs...)
 }
 
-// Close closes pool and releases all resources.
+// Close closes pool and releases all idle resources.
+// The pool is always closed even if some resources failed to release, and all errors will be joined.
+// The context is used to release resources, so the releasing stops if it's done.
 func (p *Pool[Resource]) Close(ctx context.Context) error {
 	p.lock.Lock()
-	defer p.lock.Unlock()
-
 	if p.closed {
+		p.lock.Unlock()
 		return nil
 	}
 
-	if err := p.releaseAll(ctx); err != nil {
-		return err
-	}
-
-	p.active = 0
-	p.waiting = 0
+	entries := p.drainIdle()
+	p.pendRelease(uint64(len(entries)))
+	p.active -= min(p.active, uint64(len(entries)))
+	p.stopTasks()
+	p.discarded = 0
 	p.waited = 0
 	p.waitedDuration = 0
 	p.closed = true
 
-	close(p.resources)
-	return nil
+	// Waiters will be woken up since they are dismissed, and they will find the pool is closed.
+	p.dismissWaiters()
+	p.markDrained()
+	p.lock.Unlock()
+
+	return p.releaseAll(ctx, entries)
+}
+
+// Shutdown closes pool and waits until all resources in use are returned and released.
+// The resources returned after closing will be released directly, so they won't be killed in use.
+// It stops waiting once the context is done, and the context error will be joined.
+func (p *Pool[Resource]) Shutdown(ctx context.Context) error {
+	err := p.Close(ctx)
+
+	p.lock.RLock()
+	drained := p.drained
+	p.lock.RUnlock()
+
+	select {
+	case <-drained:
+		return err
+	case <-ctx.Done():
+		p.lock.RLock()
+		active := p.active
+		p.lock.RUnlock()
+
+		return errors.Join(err, fmt.Errorf("rego: %d resources aren't returned: %w", active, ctx.Err()))
+	}
+}
+
+// ForceClose closes pool without waiting for the resources in use.
+// The contexts of leases will be canceled with ErrPoolClosed, and their capacities will be reclaimed.
+// The resources acquired without lease are still in use until they are returned, so Pool.Shutdown still waits for them.
+// The resources returned after closing will be released directly, so it's safe to release them later.
+func (p *Pool[Resource]) ForceClose(ctx context.Context) error {
+	err := p.Close(ctx)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var reclaimed uint64
+	for entry := range p.borrowed {
+		// The resources acquired without lease are still tracked so they can be released later in strict mode.
+		if entry.cancel == nil {
+			continue
+		}
+
+		entry.cancel(ErrPoolClosed)
+		delete(p.borrowed, entry)
+		p.forget(entry)
+		reclaimed++
+	}
+
+	// Only the capacities of leases are reclaimed, and the others will be freed once they are returned.
+	p.freeActive(reclaimed)
+	return err
 }
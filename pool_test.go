@@ -106,7 +106,7 @@ func TestNew(t *testing.T) {
 		}
 
 		_, err = pool.Acquire(ctx)
-		if err != wantErr {
+		if !errors.Is(err, ErrResourceUnavailable) || !errors.Is(err, wantErr) {
 			t.Fatalf("got %+v != want %+v", err, wantErr)
 		}
 	})
@@ -141,7 +141,7 @@ func TestNew(t *testing.T) {
 		}()
 
 		_, err = pool.Acquire(ctx)
-		if err != wantErr {
+		if !errors.Is(err, ErrResourceUnavailable) || !errors.Is(err, wantErr) {
 			t.Fatalf("got %+v != want %+v", err, wantErr)
 		}
 	})
@@ -196,7 +196,7 @@ func TestNew(t *testing.T) {
 		defer pool.Close(ctx)
 
 		_, err := pool.Acquire(ctx)
-		if err != wantErr {
+		if !errors.Is(err, wantErr) {
 			t.Fatalf("got %+v != want %+v", err, wantErr)
 		}
 
@@ -234,29 +234,900 @@ func TestWithAvailableFunc(t *testing.T) {
 	if got != want {
 		t.Fatalf("got %s != want %s", got, want)
 	}
-}
+}
+
+// go test -v -cover -run=^TestWithPoolClosedErrFunc$
+func TestWithPoolClosedErrFunc(t *testing.T) {
+	newClosedErr := func(context.Context) error {
+		return nil
+	}
+
+	pool := &Pool[int]{newClosedErr: nil}
+	pool.WithPoolClosedErrFunc(newClosedErr)
+
+	got := fmt.Sprintf("%p", pool.newClosedErr)
+	want := fmt.Sprintf("%p", newClosedErr)
+	if got != want {
+		t.Fatalf("got %s != want %s", got, want)
+	}
+
+	pool.WithPoolClosedErrFunc(nil)
+
+	got = fmt.Sprintf("%p", pool.newClosedErr)
+	want = fmt.Sprintf("%p", newClosedErr)
+	if got != want {
+		t.Fatalf("got %s != want %s", got, want)
+	}
+}
+
+// go test -v -cover -run=^TestWithIdleTimeout$
+func TestWithIdleTimeout(t *testing.T) {
+	ctx := context.Background()
+
+	acquire := func(context.Context) (int, error) { return 0, nil }
+	release := func(context.Context, int) error { return nil }
+
+	pool := New(1, acquire, release).WithIdleTimeout(time.Minute)
+	defer pool.Close(ctx)
+
+	if pool.idleTimeout != time.Minute {
+		t.Fatalf("got %d != want %d", pool.idleTimeout, time.Minute)
+	}
+
+	reaper := pool.reaper
+	if reaper == nil {
+		t.Fatal("pool.reaper is nil")
+	}
+
+	pool.WithIdleTimeout(time.Hour)
+	if pool.reaper == reaper {
+		t.Fatal("pool.reaper not replaced")
+	}
+
+	pool.WithIdleTimeout(0)
+	if pool.idleTimeout != 0 {
+		t.Fatalf("got %d is wrong", pool.idleTimeout)
+	}
+
+	if pool.reaper != nil {
+		t.Fatalf("got %+v is wrong", pool.reaper)
+	}
+}
+
+// go test -v -cover -run=^TestPoolIdleTimeout$
+func TestPoolIdleTimeout(t *testing.T) {
+	ctx := context.Background()
+
+	var acquired int64
+	var released int64
+	acquire := func(context.Context) (int64, error) { return atomic.AddInt64(&acquired, 1), nil }
+	release := func(context.Context, int64) error {
+		atomic.AddInt64(&released, 1)
+		return nil
+	}
+
+	pool := New(4, acquire, release).WithIdleTimeout(20 * time.Millisecond)
+	defer pool.Close(ctx)
+
+	var resources []int64
+	for range 4 {
+		resource, err := pool.Acquire(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resources = append(resources, resource)
+	}
+
+	for _, resource := range resources {
+		pool.Release(ctx, resource)
+	}
+
+	status := pool.Status()
+	if status.Idle != 4 {
+		t.Fatalf("idle %d is wrong", status.Idle)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	status = pool.Status()
+	if status.Idle != 0 {
+		t.Fatalf("idle %d is wrong", status.Idle)
+	}
+
+	if status.Using != 0 {
+		t.Fatalf("using %d is wrong", status.Using)
+	}
+
+	if got := atomic.LoadInt64(&released); got != 4 {
+		t.Fatalf("released %d is wrong", got)
+	}
+
+	// The idle resource timed out should be released when acquiring.
+	pool.WithIdleTimeout(time.Millisecond)
+
+	resource, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool.Release(ctx, resource)
+	time.Sleep(2 * time.Millisecond)
+
+	pool.lock.Lock()
+	pool.reaper.stop()
+	pool.reaper = nil
+	pool.lock.Unlock()
+
+	resource, err = pool.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resource != 6 {
+		t.Fatalf("resource %d is wrong", resource)
+	}
+
+	if got := atomic.LoadInt64(&released); got != 5 {
+		t.Fatalf("released %d is wrong", got)
+	}
+}
+
+// go test -v -cover -run=^TestWithMaxLifetime$
+func TestWithMaxLifetime(t *testing.T) {
+	ctx := context.Background()
+
+	acquire := func(context.Context) (int, error) { return 0, nil }
+	release := func(context.Context, int) error { return nil }
+
+	pool := New(1, acquire, release).WithMaxLifetime(time.Minute, time.Hour)
+	defer pool.Close(ctx)
+
+	if pool.maxLifetime != time.Minute {
+		t.Fatalf("got %d != want %d", pool.maxLifetime, time.Minute)
+	}
+
+	if pool.maxJitter != time.Minute {
+		t.Fatalf("got %d != want %d", pool.maxJitter, time.Minute)
+	}
+
+	if pool.reaper == nil {
+		t.Fatal("pool.reaper is nil")
+	}
+
+	pool.WithMaxLifetime(time.Minute, -time.Second)
+	if pool.maxJitter != 0 {
+		t.Fatalf("got %d is wrong", pool.maxJitter)
+	}
+
+	pool.WithMaxLifetime(0, time.Second)
+	if pool.maxLifetime != 0 || pool.maxJitter != 0 {
+		t.Fatalf("got %d, %d is wrong", pool.maxLifetime, pool.maxJitter)
+	}
+
+	if pool.reaper != nil {
+		t.Fatalf("got %+v is wrong", pool.reaper)
+	}
+}
+
+// go test -v -cover -run=^TestWithMaxUses$
+func TestWithMaxUses(t *testing.T) {
+	pool := &Pool[int]{maxUses: 0}
+	pool.WithMaxUses(3)
+
+	if pool.maxUses != 3 {
+		t.Fatalf("got %d != want %d", pool.maxUses, 3)
+	}
+}
+
+// go test -v -cover -run=^TestPoolMaxLifetime$
+func TestPoolMaxLifetime(t *testing.T) {
+	ctx := context.Background()
+
+	var acquired int64
+	var released int64
+	acquire := func(context.Context) (int64, error) { return atomic.AddInt64(&acquired, 1), nil }
+	release := func(context.Context, int64) error {
+		atomic.AddInt64(&released, 1)
+		return nil
+	}
+
+	pool := New(2, acquire, release).WithMaxLifetime(30*time.Millisecond, 10*time.Millisecond)
+	defer pool.Close(ctx)
+
+	resource, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool.lock.RLock()
+	entry := pool.tracked[resource][0]
+	pool.lock.RUnlock()
+
+	if entry.jitter < 0 || entry.jitter >= 10*time.Millisecond {
+		t.Fatalf("jitter %d is wrong", entry.jitter)
+	}
+
+	// The resource retired should be released instead of reusing.
+	time.Sleep(35 * time.Millisecond)
+	pool.Release(ctx, resource)
+
+	if got := atomic.LoadInt64(&released); got != 1 {
+		t.Fatalf("released %d is wrong", got)
+	}
+
+	status := pool.Status()
+	if status.Using != 0 || status.Idle != 0 {
+		t.Fatalf("status %+v is wrong", status)
+	}
+
+	// The idle resource retired should be released by reaper.
+	resource, err = pool.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool.Release(ctx, resource)
+	if status = pool.Status(); status.Idle != 1 {
+		t.Fatalf("idle %d is wrong", status.Idle)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	if got := atomic.LoadInt64(&released); got != 2 {
+		t.Fatalf("released %d is wrong", got)
+	}
+
+	if status = pool.Status(); status.Idle != 0 {
+		t.Fatalf("idle %d is wrong", status.Idle)
+	}
+}
+
+// go test -v -cover -run=^TestPoolMaxLifetimeNotComparable$
+func TestPoolMaxLifetimeNotComparable(t *testing.T) {
+	ctx := context.Background()
+
+	type resource struct {
+		id   int64
+		data []byte
+	}
+
+	use := func(pool *Pool[resource], lease bool) error {
+		if lease {
+			lease, err := pool.AcquireLease(ctx)
+			if err != nil {
+				return err
+			}
+
+			time.Sleep(5 * time.Millisecond)
+			return lease.Release(ctx)
+		}
+
+		r, err := pool.Acquire(ctx)
+		if err != nil {
+			return err
+		}
+
+		time.Sleep(5 * time.Millisecond)
+		return pool.Release(ctx, r)
+	}
+
+	testCases := []struct {
+		name     string
+		identity IdentityFunc[resource]
+		lease    bool
+		retired  bool
+	}{
+		// The lifetime restarts every time the resource is released since it can't be tracked.
+		{name: "acquire", identity: nil, lease: false, retired: false},
+		{name: "identity", identity: func(r resource) any { return r.id }, lease: false, retired: true},
+		{name: "lease", identity: nil, lease: true, retired: true},
+	}
+
+	for _, testCase := range testCases {
+		var acquired int64
+		acquire := func(context.Context) (resource, error) {
+			return resource{id: atomic.AddInt64(&acquired, 1), data: make([]byte, 8)}, nil
+		}
+
+		release := func(context.Context, resource) error { return nil }
+
+		pool := New(1, acquire, release).WithIdentityFunc(testCase.identity).WithMaxLifetime(30*time.Millisecond, 0)
+		defer pool.Close(ctx)
+
+		for range 20 {
+			if err := use(pool, testCase.lease); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if got := atomic.LoadInt64(&acquired); (got > 1) != testCase.retired {
+			t.Fatalf("%s: acquired %d is wrong", testCase.name, got)
+		}
+	}
+}
+
+// go test -v -cover -run=^TestPoolMaxUses$
+func TestPoolMaxUses(t *testing.T) {
+	ctx := context.Background()
+
+	var acquired int64
+	var released int64
+	acquire := func(context.Context) (int64, error) { return atomic.AddInt64(&acquired, 1), nil }
+	release := func(context.Context, int64) error {
+		atomic.AddInt64(&released, 1)
+		return nil
+	}
+
+	pool := New(1, acquire, release).WithMaxUses(3)
+	defer pool.Close(ctx)
+
+	for i := range 8 {
+		resource, err := pool.Acquire(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if want := int64(i/3 + 1); resource != want {
+			t.Fatalf("got %d != want %d", resource, want)
+		}
+
+		pool.Release(ctx, resource)
+	}
+
+	if got := atomic.LoadInt64(&released); got != 2 {
+		t.Fatalf("released %d is wrong", got)
+	}
+
+	// The waiter should be woken up when the resource is retired.
+	resource, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	returned := make(chan struct{})
+	go func() {
+		defer close(returned)
+
+		time.Sleep(10 * time.Millisecond)
+		pool.Release(ctx, resource)
+	}()
+
+	resource, err = pool.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resource != 4 {
+		t.Fatalf("got %d != want %d", resource, 4)
+	}
+
+	// The waiter may be woken up before the retired resource is released, so we wait for the releasing.
+	<-returned
+
+	if got := atomic.LoadInt64(&released); got != 3 {
+		t.Fatalf("released %d is wrong", got)
+	}
+}
+
+// go test -v -cover -run=^TestWithMinIdle$
+func TestWithMinIdle(t *testing.T) {
+	ctx := context.Background()
+
+	acquire := func(context.Context) (int, error) { return 0, nil }
+	release := func(context.Context, int) error { return nil }
+
+	pool := New(4, acquire, release).WithMinIdle(16)
+	defer pool.Close(ctx)
+
+	if pool.minIdle != 4 {
+		t.Fatalf("got %d != want %d", pool.minIdle, 4)
+	}
+
+	if pool.filler == nil {
+		t.Fatal("pool.filler is nil")
+	}
+
+	pool.WithMinIdle(0)
+	if pool.minIdle != 0 {
+		t.Fatalf("got %d is wrong", pool.minIdle)
+	}
+
+	if pool.filler != nil {
+		t.Fatalf("got %+v is wrong", pool.filler)
+	}
+}
+
+// go test -v -cover -run=^TestPoolMinIdle$
+func TestPoolMinIdle(t *testing.T) {
+	ctx := context.Background()
+
+	var acquired int64
+	acquire := func(context.Context) (int64, error) { return atomic.AddInt64(&acquired, 1), nil }
+	release := func(context.Context, int64) error { return nil }
+	available := func(ctx context.Context, resource int64) bool { return resource > 1 }
+
+	pool := New(4, acquire, release).WithAvailableFunc(available).WithMinIdle(2)
+	defer pool.Close(ctx)
+
+	time.Sleep(10 * time.Millisecond)
+
+	status := pool.Status()
+	if status.Idle != 2 || status.Using != 0 {
+		t.Fatalf("status %+v is wrong", status)
+	}
+
+	// The resource 1 is unavailable so it will be discarded and the filler should fill again.
+	resource, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resource != 2 {
+		t.Fatalf("got %d != want %d", resource, 2)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	status = pool.Status()
+	if status.Idle != 2 || status.Using != 1 || status.Discarded != 1 {
+		t.Fatalf("status %+v is wrong", status)
+	}
+
+	// The filler shouldn't acquire more than the limit.
+	for range 3 {
+		if _, err = pool.Acquire(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	status = pool.Status()
+	if status.Idle != 0 || status.Using != 4 {
+		t.Fatalf("status %+v is wrong", status)
+	}
+
+	if got := atomic.LoadInt64(&acquired); got != 5 {
+		t.Fatalf("acquired %d is wrong", got)
+	}
+}
+
+// go test -v -cover -run=^TestWithHealthCheck$
+func TestWithHealthCheck(t *testing.T) {
+	ctx := context.Background()
+
+	acquire := func(context.Context) (int, error) { return 0, nil }
+	release := func(context.Context, int) error { return nil }
+
+	pool := New(1, acquire, release).WithHealthCheck(time.Minute, time.Second)
+	defer pool.Close(ctx)
+
+	if pool.checkTime != time.Second {
+		t.Fatalf("got %d != want %d", pool.checkTime, time.Second)
+	}
+
+	if pool.checker == nil {
+		t.Fatal("pool.checker is nil")
+	}
+
+	pool.WithHealthCheck(0, 0)
+	if pool.checker != nil {
+		t.Fatalf("got %+v is wrong", pool.checker)
+	}
+}
+
+// go test -v -cover -run=^TestPoolHealthCheck$
+func TestPoolHealthCheck(t *testing.T) {
+	ctx := context.Background()
+
+	var acquired int64
+	var released int64
+	acquire := func(context.Context) (int64, error) { return atomic.AddInt64(&acquired, 1), nil }
+	release := func(context.Context, int64) error {
+		atomic.AddInt64(&released, 1)
+		return nil
+	}
+
+	checking := make(chan struct{})
+	checked := make(chan struct{})
+	available := func(ctx context.Context, resource int64) bool {
+		if _, ok := ctx.Deadline(); !ok {
+			return true
+		}
+
+		checking <- struct{}{}
+		<-checked
+		return resource%2 == 0
+	}
+
+	pool := New(4, acquire, release).WithAvailableFunc(available)
+	defer pool.Close(ctx)
+
+	if err := pool.Warmup(ctx, 4, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	pool.WithHealthCheck(10*time.Millisecond, time.Second)
+
+	for i := range 4 {
+		<-checking
+
+		// The resource checking shouldn't be counted as using.
+		status := pool.Status()
+		if want := uint64(3 - (i+1)/2); status.Idle != want || status.Using != 0 {
+			t.Fatalf("status %+v is wrong", status)
+		}
+
+		checked <- struct{}{}
+	}
+
+	pool.WithHealthCheck(0, 0)
+	time.Sleep(10 * time.Millisecond)
+
+	status := pool.Status()
+	if status.Idle != 2 || status.Using != 0 || status.Discarded != 2 {
+		t.Fatalf("status %+v is wrong", status)
+	}
+
+	if got := atomic.LoadInt64(&released); got != 2 {
+		t.Fatalf("released %d is wrong", got)
+	}
+}
+
+// go test -v -cover -run=^TestWithKeepalive$
+func TestWithKeepalive(t *testing.T) {
+	ctx := context.Background()
+
+	acquire := func(context.Context) (int, error) { return 0, nil }
+	release := func(context.Context, int) error { return nil }
+	keepalive := func(context.Context, int) error { return nil }
+
+	pool := New(1, acquire, release).WithKeepalive(time.Minute, keepalive)
+	defer pool.Close(ctx)
+
+	if pool.aliveTime != time.Minute {
+		t.Fatalf("got %d != want %d", pool.aliveTime, time.Minute)
+	}
+
+	if pool.keeper == nil {
+		t.Fatal("pool.keeper is nil")
+	}
+
+	pool.WithKeepalive(time.Minute, nil)
+	if pool.keeper != nil {
+		t.Fatalf("got %+v is wrong", pool.keeper)
+	}
+
+	pool.WithKeepalive(0, keepalive)
+	if pool.keeper != nil {
+		t.Fatalf("got %+v is wrong", pool.keeper)
+	}
+}
+
+// go test -v -cover -run=^TestPoolKeepalive$
+func TestPoolKeepalive(t *testing.T) {
+	ctx := context.Background()
+
+	var acquired int64
+	var released int64
+	acquire := func(context.Context) (int64, error) { return atomic.AddInt64(&acquired, 1), nil }
+	release := func(context.Context, int64) error {
+		atomic.AddInt64(&released, 1)
+		return nil
+	}
+
+	kept := sync.Map{}
+	errBroken := errors.New("broken")
+	keepalive := func(ctx context.Context, resource int64) error {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("keepalive without deadline")
+		}
+
+		if resource == 1 {
+			return errBroken
+		}
+
+		count, _ := kept.LoadOrStore(resource, new(int64))
+		atomic.AddInt64(count.(*int64), 1)
+		return nil
+	}
+
+	pool := New(4, acquire, release)
+	defer pool.Close(ctx)
+
+	if err := pool.Warmup(ctx, 3, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// The resource 3 is acquired so it won't be kept alive.
+	for range 3 {
+		if _, err := pool.Acquire(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pool.Release(ctx, 1)
+	pool.Release(ctx, 2)
+
+	pool.WithKeepalive(20*time.Millisecond, keepalive)
+	time.Sleep(70 * time.Millisecond)
+	pool.WithKeepalive(0, nil)
+
+	count, ok := kept.Load(int64(2))
+	if !ok || atomic.LoadInt64(count.(*int64)) < 1 {
+		t.Fatalf("resource 2 kept %+v is wrong", count)
+	}
+
+	if _, ok = kept.Load(int64(3)); ok {
+		t.Fatal("resource 3 shouldn't be kept alive")
+	}
+
+	status := pool.Status()
+	if status.Idle != 1 || status.Using != 1 || status.Discarded != 1 {
+		t.Fatalf("status %+v is wrong", status)
+	}
+
+	if got := atomic.LoadInt64(&released); got != 1 {
+		t.Fatalf("released %d is wrong", got)
+	}
+}
+
+// go test -v -cover -run=^TestPoolDiscard$
+func TestPoolDiscard(t *testing.T) {
+	ctx := context.Background()
+
+	var acquired int64
+	var released int64
+	acquire := func(context.Context) (int64, error) { return atomic.AddInt64(&acquired, 1), nil }
+	release := func(context.Context, int64) error {
+		atomic.AddInt64(&released, 1)
+		return nil
+	}
+
+	pool := New(1, acquire, release)
+	defer pool.Close(ctx)
+
+	resource, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The waiter should acquire a new resource after discarding.
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+
+		if err := pool.Discard(ctx, resource); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	resource, err = pool.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resource != 2 {
+		t.Fatalf("got %d != want %d", resource, 2)
+	}
+
+	if got := atomic.LoadInt64(&released); got != 1 {
+		t.Fatalf("released %d is wrong", got)
+	}
+
+	if err = pool.Discard(ctx, resource); err != nil {
+		t.Fatal(err)
+	}
+
+	status := pool.Status()
+	if status.Using != 0 || status.Idle != 0 || status.Discarded != 2 {
+		t.Fatalf("status %+v is wrong", status)
+	}
+
+	// Discarding a resource not acquired from pool shouldn't overflow the active.
+	if err = pool.Discard(ctx, 100); err != nil {
+		t.Fatal(err)
+	}
+
+	status = pool.Status()
+	if status.Using != 0 || status.Idle != 0 || status.Discarded != 3 {
+		t.Fatalf("status %+v is wrong", status)
+	}
+
+	if got := atomic.LoadInt64(&released); got != 3 {
+		t.Fatalf("released %d is wrong", got)
+	}
+
+	pool.Close(ctx)
+
+	if err = pool.Discard(ctx, 100); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := atomic.LoadInt64(&released); got != 4 {
+		t.Fatalf("released %d is wrong", got)
+	}
+}
+
+// go test -v -cover -run=^TestWithIdentityFunc$
+func TestWithIdentityFunc(t *testing.T) {
+	identity := func(int) any {
+		return 0
+	}
+
+	pool := &Pool[int]{identity: nil}
+	pool.WithIdentityFunc(identity)
+
+	got := fmt.Sprintf("%p", pool.identity)
+	want := fmt.Sprintf("%p", identity)
+	if got != want {
+		t.Fatalf("got %s != want %s", got, want)
+	}
+
+	pool.WithIdentityFunc(nil)
+
+	got = fmt.Sprintf("%p", pool.identity)
+	want = fmt.Sprintf("%p", identity)
+	if got != want {
+		t.Fatalf("got %s != want %s", got, want)
+	}
+}
+
+// go test -v -cover -run=^TestWithStrictRelease$
+func TestWithStrictRelease(t *testing.T) {
+	pool := &Pool[int]{strict: false}
+	pool.WithStrictRelease(true)
+
+	if !pool.strict {
+		t.Fatalf("got %+v is wrong", pool.strict)
+	}
+}
+
+// go test -v -cover -run=^TestPoolRemember$
+func TestPoolRemember(t *testing.T) {
+	acquire := func(context.Context) (int, error) { return 0, nil }
+	release := func(context.Context, int) error { return nil }
+
+	pool := New(1, acquire, release)
+	for key := range maxForgotten + 1 {
+		pool.remember(key)
+		pool.remember(key)
+	}
+
+	if len(pool.forgotten) != maxForgotten || len(pool.forgets) != maxForgotten {
+		t.Fatalf("got %d, %d != want %d", len(pool.forgotten), len(pool.forgets), maxForgotten)
+	}
+
+	// The oldest key is forgotten once the records are full.
+	if _, ok := pool.forgotten[0]; ok {
+		t.Fatalf("got %+v is wrong", pool.forgotten)
+	}
+
+	if _, ok := pool.forgotten[maxForgotten]; !ok {
+		t.Fatalf("got %+v is wrong", pool.forgotten)
+	}
+}
+
+// go test -v -cover -run=^TestPoolStrictRelease$
+func TestPoolStrictRelease(t *testing.T) {
+	ctx := context.Background()
+
+	// The resource isn't comparable because of the data field.
+	type Resource struct {
+		id   int64
+		data []byte
+	}
+
+	var acquired int64
+	var released int64
+	acquire := func(context.Context) (Resource, error) {
+		resource := Resource{id: atomic.AddInt64(&acquired, 1)}
+		return resource, nil
+	}
+
+	release := func(context.Context, Resource) error {
+		atomic.AddInt64(&released, 1)
+		return nil
+	}
+
+	identity := func(resource Resource) any { return resource.id }
+
+	pool := New(2, acquire, release).WithIdentityFunc(identity).WithStrictRelease(true)
+	defer pool.Close(ctx)
+
+	resource, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = pool.Release(ctx, resource); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = pool.Release(ctx, resource); err != ErrDoubleRelease {
+		t.Fatalf("got %+v != want %+v", err, ErrDoubleRelease)
+	}
+
+	if err = pool.Discard(ctx, resource); err != ErrDoubleRelease {
+		t.Fatalf("got %+v != want %+v", err, ErrDoubleRelease)
+	}
+
+	foreign := Resource{id: 100}
+	if err = pool.Release(ctx, foreign); err != ErrForeignResource {
+		t.Fatalf("got %+v != want %+v", err, ErrForeignResource)
+	}
+
+	if err = pool.Discard(ctx, foreign); err != ErrForeignResource {
+		t.Fatalf("got %+v != want %+v", err, ErrForeignResource)
+	}
+
+	status := pool.Status()
+	if status.Using != 0 || status.Idle != 1 {
+		t.Fatalf("status %+v is wrong", status)
+	}
+
+	// The resource discarded can't be released or discarded again, and it's remembered by pool.
+	resource, err = pool.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = pool.Discard(ctx, resource); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = pool.Release(ctx, resource); err != ErrDoubleRelease {
+		t.Fatalf("got %+v != want %+v", err, ErrDoubleRelease)
+	}
+
+	if err = pool.Discard(ctx, resource); err != ErrDoubleRelease {
+		t.Fatalf("got %+v != want %+v", err, ErrDoubleRelease)
+	}
+
+	// The resource retired can't be released again either.
+	pool.WithMaxUses(1)
+
+	resource, err = pool.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = pool.Release(ctx, resource); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = pool.Release(ctx, resource); err != ErrDoubleRelease {
+		t.Fatalf("got %+v != want %+v", err, ErrDoubleRelease)
+	}
+
+	pool.WithMaxUses(0)
+
+	if err = pool.Release(ctx, foreign); err != ErrForeignResource {
+		t.Fatalf("got %+v != want %+v", err, ErrForeignResource)
+	}
 
-// go test -v -cover -run=^TestWithPoolClosedErrFunc$
-func TestWithPoolClosedErrFunc(t *testing.T) {
-	newClosedErr := func(context.Context) error {
-		return nil
+	if got := atomic.LoadInt64(&released); got != 2 {
+		t.Fatalf("released %d is wrong", got)
 	}
 
-	pool := &Pool[int]{newClosedErr: nil}
-	pool.WithPoolClosedErrFunc(newClosedErr)
+	status = pool.Status()
+	if status.Using != 0 || status.Idle != 0 {
+		t.Fatalf("status %+v is wrong", status)
+	}
 
-	got := fmt.Sprintf("%p", pool.newClosedErr)
-	want := fmt.Sprintf("%p", newClosedErr)
-	if got != want {
-		t.Fatalf("got %s != want %s", got, want)
+	if len(pool.known) != 0 || len(pool.tracked) != 0 || len(pool.borrowed) != 0 {
+		t.Fatalf("known %+v, tracked %+v, borrowed %+v is wrong", pool.known, pool.tracked, pool.borrowed)
 	}
 
-	pool.WithPoolClosedErrFunc(nil)
+	// The resources are released without checking if the strict mode is off.
+	pool.WithStrictRelease(false)
 
-	got = fmt.Sprintf("%p", pool.newClosedErr)
-	want = fmt.Sprintf("%p", newClosedErr)
-	if got != want {
-		t.Fatalf("got %s != want %s", got, want)
+	if err = pool.Release(ctx, foreign); err != nil {
+		t.Fatal(err)
+	}
+
+	if status = pool.Status(); status.Idle != 1 {
+		t.Fatalf("status %+v is wrong", status)
 	}
 }
 
@@ -514,8 +1385,8 @@ func TestPoolClose(t *testing.T) {
 	}
 
 	_, err = pool.Acquire(ctx)
-	if err != errPoolClosed {
-		t.Fatalf("got %+v != want %+v", err, errPoolClosed)
+	if !errors.Is(err, ErrPoolClosed) {
+		t.Fatalf("got %+v != want %+v", err, ErrPoolClosed)
 	}
 
 	err = pool.Release(ctx, 0)
@@ -528,6 +1399,343 @@ func TestPoolClose(t *testing.T) {
 	}
 }
 
+// go test -v -cover -run=^TestPoolCloseErrors$
+func TestPoolCloseErrors(t *testing.T) {
+	ctx := context.Background()
+
+	released := 0
+	acquire := func(context.Context) (int, error) { return 0, nil }
+	release := func(_ context.Context, resource int) error {
+		released++
+
+		if resource > 0 {
+			return fmt.Errorf("release %d failed", resource)
+		}
+
+		return nil
+	}
+
+	pool := New(3, acquire, release)
+	for i := range 3 {
+		if err := pool.Release(ctx, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := pool.Close(ctx)
+	if err == nil || err.Error() != "release 1 failed\nrelease 2 failed" {
+		t.Fatalf("got %+v is wrong", err)
+	}
+
+	if released != 3 {
+		t.Fatalf("got %d != want %d", released, 3)
+	}
+
+	if !pool.closed {
+		t.Fatalf("got %+v is wrong", pool.closed)
+	}
+
+	if err = pool.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	released = 0
+	pool = New(3, acquire, release)
+	for range 3 {
+		if err = pool.Release(ctx, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	canceledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+
+	err = pool.Close(canceledCtx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %+v is wrong", err)
+	}
+
+	if released != 0 {
+		t.Fatalf("got %d != want %d", released, 0)
+	}
+
+	if !pool.closed {
+		t.Fatalf("got %+v is wrong", pool.closed)
+	}
+}
+
+// go test -v -cover -run=^TestPoolShutdown$
+func TestPoolShutdown(t *testing.T) {
+	ctx := context.Background()
+
+	var released atomic.Int64
+	acquire := func(context.Context) (int, error) { return 0, nil }
+	release := func(context.Context, int) error {
+		released.Add(1)
+		return nil
+	}
+
+	pool := New(2, acquire, release)
+
+	resource, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lease, err := pool.AcquireLease(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = pool.Release(ctx, resource); err != nil {
+		t.Fatal(err)
+	}
+
+	resource, err = pool.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitErr := make(chan error, 1)
+	go func() {
+		_, err := pool.Acquire(ctx)
+		waitErr <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- pool.Shutdown(ctx)
+	}()
+
+	if err = <-waitErr; !errors.Is(err, ErrPoolClosed) {
+		t.Fatalf("got %+v != want %+v", err, ErrPoolClosed)
+	}
+
+	if err = pool.Release(ctx, resource); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err = <-shutdownErr:
+		t.Fatalf("shutdown returns %+v before all resources returned", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	if err = lease.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = <-shutdownErr; err != nil {
+		t.Fatal(err)
+	}
+
+	if released.Load() != 2 {
+		t.Fatalf("got %d != want %d", released.Load(), 2)
+	}
+
+	pool = New(1, acquire, release)
+	if _, err = pool.Acquire(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+
+	if err = pool.Shutdown(timeoutCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %+v is wrong", err)
+	}
+}
+
+// go test -v -cover -run=^TestPoolShutdownReleasing$
+func TestPoolShutdownReleasing(t *testing.T) {
+	ctx := context.Background()
+
+	var released atomic.Int64
+	acquire := func(context.Context) (int, error) { return 0, nil }
+	release := func(context.Context, int) error {
+		time.Sleep(100 * time.Millisecond)
+		released.Add(1)
+		return nil
+	}
+
+	pool := New(2, acquire, release)
+
+	resource, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lease, err := pool.AcquireLease(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- pool.Shutdown(ctx)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+
+	go pool.Release(ctx, resource)
+	go lease.Discard(ctx)
+
+	// The resources returned should be released before shutdown returns.
+	if err = <-shutdownErr; err != nil {
+		t.Fatal(err)
+	}
+
+	if released.Load() != 2 {
+		t.Fatalf("got %d != want %d", released.Load(), 2)
+	}
+}
+
+// go test -v -cover -run=^TestPoolForceClose$
+func TestPoolForceClose(t *testing.T) {
+	ctx := context.Background()
+
+	var released atomic.Int64
+	acquire := func(context.Context) (int, error) { return int(released.Load()), nil }
+	release := func(context.Context, int) error {
+		released.Add(1)
+		return nil
+	}
+
+	pool := New(3, acquire, release).WithStrictRelease(true)
+
+	lease, err := pool.AcquireLease(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resource, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- pool.Shutdown(ctx)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+
+	if err = pool.ForceClose(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if cause := context.Cause(lease.Context()); cause != ErrPoolClosed {
+		t.Fatalf("got %+v != want %+v", cause, ErrPoolClosed)
+	}
+
+	// Only the capacity of lease is reclaimed, and the resource acquired without lease is still in use.
+	if status := pool.Status(); status.Using != 1 {
+		t.Fatalf("got %+v is wrong", status)
+	}
+
+	select {
+	case err = <-shutdownErr:
+		t.Fatalf("shutdown returns %+v before all resources returned", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	if err = lease.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = pool.Release(ctx, resource); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = <-shutdownErr; err != nil {
+		t.Fatal(err)
+	}
+
+	if released.Load() != 2 {
+		t.Fatalf("got %d != want %d", released.Load(), 2)
+	}
+
+	if status := pool.Status(); status.Using != 0 {
+		t.Fatalf("got %+v is wrong", status)
+	}
+}
+
+// go test -v -cover -run=^TestPoolForceCloseChecking$
+func TestPoolForceCloseChecking(t *testing.T) {
+	ctx := context.Background()
+
+	var released atomic.Int64
+	acquire := func(context.Context) (int, error) { return 0, nil }
+	release := func(context.Context, int) error {
+		released.Add(1)
+		return nil
+	}
+
+	pool := New(2, acquire, release)
+
+	resource, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = pool.Release(ctx, resource); err != nil {
+		t.Fatal(err)
+	}
+
+	visiting := make(chan struct{})
+	visited := make(chan struct{})
+	go func() {
+		defer close(visited)
+
+		pool.visitIdle(ctx, func(context.Context, *entry[int]) bool {
+			close(visiting)
+			time.Sleep(20 * time.Millisecond)
+			return true
+		})
+	}()
+
+	<-visiting
+
+	resource, err = pool.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = pool.ForceClose(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The resource being checked is still active, so the using shouldn't underflow.
+	if status := pool.Status(); status.Using != 1 || status.Idle != 0 {
+		t.Fatalf("got %+v is wrong", status)
+	}
+
+	// The resource acquired without lease shouldn't take the active of the one being checked.
+	if err = pool.Release(ctx, resource); err != nil {
+		t.Fatal(err)
+	}
+
+	if status := pool.Status(); status.Using != 0 || status.Idle != 0 {
+		t.Fatalf("got %+v is wrong", status)
+	}
+
+	<-visited
+
+	if err = pool.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if released.Load() != 2 {
+		t.Fatalf("got %d != want %d", released.Load(), 2)
+	}
+
+	if status := pool.Status(); status.Using != 0 || pool.active != 0 {
+		t.Fatalf("got %+v is wrong", status)
+	}
+}
+
 // go test -v -cover -run=^TestPoolTimeout$
 func TestPoolTimeout(t *testing.T) {
 	ctx := context.Background()
@@ -551,7 +1759,52 @@ func TestPoolTimeout(t *testing.T) {
 	}
 
 	_, err = pool.Acquire(ctx)
-	if err != ctx.Err() {
+	if !errors.Is(err, ErrAcquireTimeout) || !errors.Is(err, ctx.Err()) {
 		t.Fatalf("got %+v != want %+v", err, ctx.Err())
 	}
 }
+
+// go test -v -cover -run=^TestPoolTryAcquire$
+func TestPoolTryAcquire(t *testing.T) {
+	ctx := context.Background()
+
+	acquire := func(context.Context) (int, error) { return 1, nil }
+	release := func(context.Context, int) error { return nil }
+
+	pool := New(1, acquire, release)
+	defer pool.Close(ctx)
+
+	resource, err := pool.TryAcquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resource != 1 {
+		t.Fatalf("got %d != want %d", resource, 1)
+	}
+
+	_, err = pool.TryAcquire(ctx)
+	if !errors.Is(err, ErrPoolExhausted) {
+		t.Fatalf("got %+v != want %+v", err, ErrPoolExhausted)
+	}
+
+	if err = pool.Release(ctx, resource); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = pool.TryAcquire(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	status := pool.Status()
+	if status.Using != 1 || status.Waiting != 0 || status.WaitDuration != 0 || pool.waited != 0 {
+		t.Fatalf("got %+v is wrong", status)
+	}
+
+	pool.Close(ctx)
+
+	_, err = pool.TryAcquire(ctx)
+	if !errors.Is(err, ErrPoolClosed) {
+		t.Fatalf("got %+v != want %+v", err, ErrPoolClosed)
+	}
+}
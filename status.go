@@ -22,4 +22,7 @@ type Status struct {
 
 	// WaitDuration is the average duration waiting a resource.
 	WaitDuration time.Duration `json:"wait_duration"`
+
+	// Discarded is the quantity of resources discarded because they are broken.
+	Discarded uint64 `json:"discarded"`
 }
@@ -19,11 +19,12 @@ func TestStatus(t *testing.T) {
 		waiting:        100,
 		waited:         50,
 		waitedDuration: 100 * time.Millisecond,
-		resources:      make(chan int, limit),
+		discarded:      3,
+		idle:           make([]*entry[int], 0, limit),
 	}
 
 	for i := range 10 {
-		pool.resources <- i
+		pool.idle = append(pool.idle, &entry[int]{resource: i})
 	}
 
 	want := Status{
@@ -32,6 +33,7 @@ func TestStatus(t *testing.T) {
 		Idle:         10,
 		Waiting:      100,
 		WaitDuration: 2 * time.Millisecond,
+		Discarded:    3,
 	}
 
 	got := pool.Status()
@@ -0,0 +1,64 @@
+// Copyright 2025 FishGoddess. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package rego
+
+import (
+	"context"
+	"time"
+)
+
+// task runs a function in a goroutine periodically until it's stopped.
+type task struct {
+	interval time.Duration
+	fn       func(ctx context.Context)
+	wakeCh   chan struct{}
+	stopCh   chan struct{}
+}
+
+func newTask(interval time.Duration, fn func(ctx context.Context)) *task {
+	task := &task{
+		interval: interval,
+		fn:       fn,
+		wakeCh:   make(chan struct{}, 1),
+		stopCh:   make(chan struct{}),
+	}
+
+	return task
+}
+
+// run runs the task and blocks until the task is stopped.
+func (t *task) run() {
+	ctx := context.Background()
+
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			t.fn(ctx)
+		case <-t.wakeCh:
+			t.fn(ctx)
+		case <-t.stopCh:
+			return
+		}
+	}
+}
+
+// wake wakes up the task to run the function immediately without blocking.
+func (t *task) wake() {
+	select {
+	case t.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// stop stops the task and it should be called only once.
+// It's safe to stop a nil task.
+func (t *task) stop() {
+	if t != nil {
+		close(t.stopCh)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2025 FishGoddess. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package rego
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// go test -v -cover -run=^TestTask$
+func TestTask(t *testing.T) {
+	var count int64
+	fn := func(ctx context.Context) {
+		atomic.AddInt64(&count, 1)
+	}
+
+	task := newTask(10*time.Millisecond, fn)
+
+	done := make(chan struct{})
+	go func() {
+		task.run()
+		close(done)
+	}()
+
+	time.Sleep(55 * time.Millisecond)
+	task.stop()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("task not stopped")
+	}
+
+	got := atomic.LoadInt64(&count)
+	if got < 3 || got > 6 {
+		t.Fatalf("got %d is wrong", got)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if atomic.LoadInt64(&count) != got {
+		t.Fatalf("got %d != want %d", atomic.LoadInt64(&count), got)
+	}
+}
+
+// go test -v -cover -run=^TestTaskWake$
+func TestTaskWake(t *testing.T) {
+	var count int64
+	fn := func(ctx context.Context) {
+		atomic.AddInt64(&count, 1)
+	}
+
+	task := newTask(time.Hour, fn)
+	go task.run()
+	defer task.stop()
+
+	task.wake()
+	time.Sleep(10 * time.Millisecond)
+
+	if got := atomic.LoadInt64(&count); got != 1 {
+		t.Fatalf("got %d != want %d", got, 1)
+	}
+
+	for range 10 {
+		task.wake()
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	if got := atomic.LoadInt64(&count); got < 2 || got > 3 {
+		t.Fatalf("got %d is wrong", got)
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright 2025 FishGoddess. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package rego
+
+import (
+	"container/heap"
+	"context"
+	"math"
+	"time"
+)
+
+// waiter is a caller waiting for a resource in pool.
+type waiter[Resource any] struct {
+	rank  int64
+	seq   uint64
+	index int
+
+	// need is the quantity of grants the waiter still needs, and it stays in the queue until need is 0.
+	need uint64
+
+	// ready receives an idle entry or a nil entry which means an active is reserved for the waiter.
+	// It will be closed if the pool is closed.
+	ready chan *entry[Resource]
+}
+
+// waiters is a priority queue of waiters, and the waiter with the lowest rank will be popped first.
+type waiters[Resource any] []*waiter[Resource]
+
+func (ws waiters[Resource]) Len() int {
+	return len(ws)
+}
+
+func (ws waiters[Resource]) Less(i, j int) bool {
+	if ws[i].rank != ws[j].rank {
+		return ws[i].rank < ws[j].rank
+	}
+
+	return ws[i].seq < ws[j].seq
+}
+
+func (ws waiters[Resource]) Swap(i, j int) {
+	ws[i], ws[j] = ws[j], ws[i]
+	ws[i].index = i
+	ws[j].index = j
+}
+
+func (ws *waiters[Resource]) Push(x any) {
+	w := x.(*waiter[Resource])
+	w.index = len(*ws)
+	*ws = append(*ws, w)
+}
+
+func (ws *waiters[Resource]) Pop() any {
+	old := *ws
+	last := len(old) - 1
+
+	w := old[last]
+	w.index = -1
+	old[last] = nil
+
+	*ws = old[:last]
+	return w
+}
+
+// WithPriorityAging sets the aging of waiters so the ones with low priority won't starve.
+// The priority of a waiter increases by one for every aging it waits, and aging <= 0 means no aging.
+func (p *Pool[Resource]) WithPriorityAging(aging time.Duration) *Pool[Resource] {
+	p.lock.Lock()
+	p.aging = aging
+	p.lock.Unlock()
+
+	return p
+}
+
+// WithFairness sets the pool to fair mode so the waiters will be granted in strict FIFO order.
+// New callers will queue behind the waiters instead of taking the idle resources ahead of them.
+// The priorities of waiters are ignored in fair mode.
+func (p *Pool[Resource]) WithFairness(fair bool) *Pool[Resource] {
+	p.lock.Lock()
+	p.fair = fair
+	p.lock.Unlock()
+
+	return p
+}
+
+// WithMaxWaiting sets the max quantity of waiters, and maxWaiting == 0 means no limit.
+// New callers will fail with ErrPoolExhausted immediately instead of waiting if the waiters reach the max waiting.
+func (p *Pool[Resource]) WithMaxWaiting(maxWaiting uint64) *Pool[Resource] {
+	p.lock.Lock()
+	p.maxWaiting = maxWaiting
+	p.lock.Unlock()
+
+	return p
+}
+
+// overloaded returns true if the waiters reach the max waiting, so new callers should be rejected.
+// It should be called with lock held.
+func (p *Pool[Resource]) overloaded() bool {
+	return p.maxWaiting > 0 && p.waiting >= p.maxWaiting
+}
+
+// queued returns true if new callers should queue behind the waiters in fair mode.
+// It should be called with lock held.
+func (p *Pool[Resource]) queued() bool {
+	return p.fair && p.waiters.Len() > 0
+}
+
+// agingRank returns the rank of a waiter enqueued at now with priority, and it saturates instead of overflowing.
+// The boost of priority is limited to half of int64 so subtracting it from a unix nano time won't overflow.
+func agingRank(now int64, priority int, aging time.Duration) int64 {
+	bound := math.MaxInt64 / 2 / int64(aging)
+	boost := min(max(int64(priority), -bound), bound) * int64(aging)
+	return now - boost
+}
+
+// enqueue creates a waiter needing n grants with priority and puts it into the queue.
+// It should be called with lock held.
+func (p *Pool[Resource]) enqueue(priority int, n uint64) *waiter[Resource] {
+	// The rank decreases with priority, and it increases with the time enqueued if aging is set.
+	// So the waiters waiting long enough will be popped first even if their priorities are lower.
+	rank := -max(int64(priority), -math.MaxInt64)
+	if p.fair {
+		rank = 0
+	} else if p.aging > 0 {
+		rank = agingRank(time.Now().UnixNano(), priority, p.aging)
+	}
+
+	p.seq++
+
+	w := &waiter[Resource]{
+		rank:  rank,
+		seq:   p.seq,
+		need:  n,
+		ready: make(chan *entry[Resource], n),
+	}
+
+	heap.Push(&p.waiters, w)
+	p.waiting++
+	return w
+}
+
+// handOver hands the entry to the waiter with the highest priority and returns false if no waiters.
+// A nil entry means an active is reserved for the waiter.
+// The waiter keeps the grants and stays in the queue until it has all grants it needs.
+// It should be called with lock held.
+func (p *Pool[Resource]) handOver(entry *entry[Resource]) bool {
+	if p.waiters.Len() <= 0 {
+		return false
+	}
+
+	// The waiter holding part of its grants is always served first, or the batch waiters may split the grants
+	// with each other and none of them can get all it needs.
+	w := p.holder
+	if w == nil {
+		w = p.waiters[0]
+
+		if p.congested {
+			// Serve the fresh waiters first, so the stale ones will time out fast.
+			w = p.newest()
+		}
+	}
+
+	w.ready <- entry
+	w.need--
+	p.holder = w
+
+	if w.need <= 0 {
+		heap.Remove(&p.waiters, w.index)
+		p.holder = nil
+	}
+
+	return true
+}
+
+// grantActive reserves the free active for the waiters so they can acquire new resources.
+// It should be called with lock held.
+func (p *Pool[Resource]) grantActive() {
+	for !p.closed && p.active < p.limit && p.handOver(nil) {
+		p.active++
+	}
+}
+
+// dismissWaiters wakes up all waiters in the queue and they will find the pool is closed.
+// It should be called with lock held.
+func (p *Pool[Resource]) dismissWaiters() {
+	for _, w := range p.waiters {
+		w.index = -1
+		close(w.ready)
+	}
+
+	p.waiters = nil
+	p.holder = nil
+}
+
+// waitEntry waits until the waiter is granted an idle entry or an active, and ok is false if the pool is closed.
+// A nil entry will be returned if an active is reserved for the waiter, and the waiter should acquire a new resource.
+// The grant will be taken if the waiter has been granted when the context is done.
+func (p *Pool[Resource]) waitEntry(ctx context.Context, w *waiter[Resource]) (entry *entry[Resource], ok bool, err error) {
+	select {
+	case entry, ok = <-w.ready:
+		return entry, ok, nil
+	case <-ctx.Done():
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if w.index >= 0 {
+		heap.Remove(&p.waiters, w.index)
+		return nil, false, ctx.Err()
+	}
+
+	// The waiter is granted or dismissed, so the channel won't block.
+	entry, ok = <-w.ready
+	return entry, ok, nil
+}
@@ -0,0 +1,274 @@
+// Copyright 2025 FishGoddess. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package rego
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// go test -v -cover -run=^TestWaiters$
+func TestWaiters(t *testing.T) {
+	removed := &waiter[int]{rank: 1, seq: 4}
+
+	var ws waiters[int]
+	heap.Push(&ws, &waiter[int]{rank: 3, seq: 1})
+	heap.Push(&ws, &waiter[int]{rank: 1, seq: 2})
+	heap.Push(&ws, &waiter[int]{rank: 2, seq: 3})
+	heap.Push(&ws, removed)
+	heap.Push(&ws, &waiter[int]{rank: 1, seq: 5})
+
+	heap.Remove(&ws, removed.index)
+	if removed.index != -1 {
+		t.Fatalf("got %d != want %d", removed.index, -1)
+	}
+
+	want := []uint64{2, 5, 3, 1}
+	for _, seq := range want {
+		w := heap.Pop(&ws).(*waiter[int])
+		if w.seq != seq {
+			t.Fatalf("got %d != want %d", w.seq, seq)
+		}
+	}
+
+	if ws.Len() != 0 {
+		t.Fatalf("got %d != want %d", ws.Len(), 0)
+	}
+}
+
+// go test -v -cover -run=^TestPoolEnqueuePriority$
+func TestPoolEnqueuePriority(t *testing.T) {
+	acquire := func(context.Context) (int, error) { return 0, nil }
+	release := func(context.Context, int) error { return nil }
+
+	testCases := []struct {
+		aging time.Duration
+		want  []uint64
+	}{
+		{aging: 0, want: []uint64{5, 4, 3, 2, 1}},
+		{aging: time.Second, want: []uint64{5, 4, 3, 2, 1}},
+		{aging: time.Hour, want: []uint64{5, 4, 3, 2, 1}},
+		// All priorities are treated as the bound 0 since the aging is too long.
+		{aging: math.MaxInt64, want: []uint64{1, 2, 3, 4, 5}},
+	}
+
+	for _, testCase := range testCases {
+		pool := New(1, acquire, release).WithPriorityAging(testCase.aging)
+
+		pool.lock.Lock()
+		for _, priority := range []int{math.MinInt, -1, 0, 1, math.MaxInt} {
+			pool.enqueue(priority, 1)
+		}
+
+		// The extreme priorities shouldn't overflow and be granted in the wrong order.
+		var got []uint64
+		for pool.waiters.Len() > 0 {
+			got = append(got, heap.Pop(&pool.waiters).(*waiter[int]).seq)
+		}
+
+		pool.lock.Unlock()
+
+		if !slices.Equal(got, testCase.want) {
+			t.Fatalf("aging %s: got %+v != want %+v", testCase.aging, got, testCase.want)
+		}
+	}
+}
+
+// go test -v -cover -run=^TestPoolAcquireWithPriority$
+func TestPoolAcquireWithPriority(t *testing.T) {
+	ctx := context.Background()
+
+	acquire := func(context.Context) (int, error) { return 0, nil }
+	release := func(context.Context, int) error { return nil }
+
+	testOrder := func(t *testing.T, pool *Pool[int], delay time.Duration, want []int) {
+		resource, err := pool.Acquire(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		order := make(chan int, len(want))
+		for _, priority := range []int{0, 10} {
+			go func() {
+				resource, err := pool.AcquireWithPriority(ctx, priority)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+
+				order <- priority
+				pool.Release(ctx, resource)
+			}()
+
+			time.Sleep(delay)
+		}
+
+		pool.Release(ctx, resource)
+
+		for _, priority := range want {
+			if got := <-order; got != priority {
+				t.Fatalf("got %d != want %d", got, priority)
+			}
+		}
+	}
+
+	pool := New(1, acquire, release).WithPriorityAging(0)
+	defer pool.Close(ctx)
+
+	testOrder(t, pool, 10*time.Millisecond, []int{10, 0})
+
+	// The low priority waiter has waited long enough to be granted first.
+	pool = New(1, acquire, release).WithPriorityAging(time.Millisecond)
+	defer pool.Close(ctx)
+
+	testOrder(t, pool, 50*time.Millisecond, []int{0, 10})
+
+	// The priorities are ignored in fair mode.
+	pool = New(1, acquire, release).WithFairness(true)
+	defer pool.Close(ctx)
+
+	testOrder(t, pool, 10*time.Millisecond, []int{0, 10})
+}
+
+// go test -v -cover -run=^TestPoolFairness$
+func TestPoolFairness(t *testing.T) {
+	ctx := context.Background()
+
+	acquire := func(context.Context) (int, error) { return 0, nil }
+	release := func(context.Context, int) error { return nil }
+
+	for _, fair := range []bool{false, true} {
+		pool := New(2, acquire, release).WithFairness(fair)
+		defer pool.Close(ctx)
+
+		if err := pool.Release(ctx, 0); err != nil {
+			t.Fatal(err)
+		}
+
+		pool.lock.Lock()
+		w := pool.enqueue(0, 1)
+		pool.lock.Unlock()
+
+		// The new caller can't take the idle resource or create a new one ahead of the waiter in fair mode.
+		_, err := pool.TryAcquire(ctx)
+		if fair && !errors.Is(err, ErrPoolExhausted) {
+			t.Fatalf("got %+v != want %+v", err, ErrPoolExhausted)
+		}
+
+		if !fair && err != nil {
+			t.Fatal(err)
+		}
+
+		if fair {
+			// The idle resource is handed to the waiter.
+			if entry := <-w.ready; entry == nil || entry.resource != 0 {
+				t.Fatalf("got %+v is wrong", entry)
+			}
+		}
+	}
+}
+
+// go test -v -cover -run=^TestPoolGrantActive$
+func TestPoolGrantActive(t *testing.T) {
+	ctx := context.Background()
+
+	var acquired atomic.Int64
+	acquire := func(context.Context) (int64, error) { return acquired.Add(1), nil }
+	release := func(context.Context, int64) error { return nil }
+
+	pool := New(1, acquire, release)
+	defer pool.Close(ctx)
+
+	resource, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+
+	if _, err = pool.Acquire(timeoutCtx); !errors.Is(err, ErrAcquireTimeout) {
+		t.Fatalf("got %+v != want %+v", err, ErrAcquireTimeout)
+	}
+
+	if pool.waiters.Len() != 0 || pool.waiting != 0 {
+		t.Fatalf("got %d waiters and %d waiting", pool.waiters.Len(), pool.waiting)
+	}
+
+	granted := make(chan int64, 1)
+	go func() {
+		resource, err := pool.Acquire(ctx)
+		if err != nil {
+			t.Error(err)
+		}
+
+		granted <- resource
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+
+	if err = pool.Discard(ctx, resource); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := <-granted; got != 2 {
+		t.Fatalf("got %d != want %d", got, 2)
+	}
+
+	status := pool.Status()
+	if status.Using != 1 || status.Idle != 0 || status.Waiting != 0 {
+		t.Fatalf("got %+v is wrong", status)
+	}
+}
+
+// go test -v -cover -run=^TestPoolMaxWaiting$
+func TestPoolMaxWaiting(t *testing.T) {
+	ctx := context.Background()
+
+	acquire := func(context.Context) (int, error) { return 0, nil }
+	release := func(context.Context, int) error { return nil }
+
+	pool := New(1, acquire, release).WithMaxWaiting(1)
+	defer pool.Close(ctx)
+
+	resource, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	acquired := make(chan error, 1)
+	go func() {
+		_, err := pool.Acquire(ctx)
+		acquired <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+
+	if _, err = pool.Acquire(ctx); !errors.Is(err, ErrPoolExhausted) {
+		t.Fatalf("got %+v != want %+v", err, ErrPoolExhausted)
+	}
+
+	if _, err = pool.AcquireN(ctx, 1); !errors.Is(err, ErrPoolExhausted) {
+		t.Fatalf("got %+v != want %+v", err, ErrPoolExhausted)
+	}
+
+	if err = pool.Release(ctx, resource); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = <-acquired; err != nil {
+		t.Fatal(err)
+	}
+
+	if status := pool.Status(); status.Waiting != 0 || status.Using != 1 {
+		t.Fatalf("got %+v is wrong", status)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2025 FishGoddess. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package rego
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// warmupOne acquires a new resource and puts it into pool as an idle resource.
+// The active should be increased before calling it.
+func (p *Pool[Resource]) warmupOne(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		p.lock.Lock()
+		p.freeActive(1)
+		p.lock.Unlock()
+
+		return err
+	}
+
+	resource, err := p.acquireResource(ctx)
+	if err != nil {
+		p.lock.Lock()
+		p.freeActive(1)
+		p.lock.Unlock()
+
+		return err
+	}
+
+	p.lock.Lock()
+	entry := p.newEntry(resource)
+	reused := p.reuse(entry)
+	p.lock.Unlock()
+
+	if reused {
+		return nil
+	}
+
+	return p.releasePending(ctx, resource)
+}
+
+// Warmup acquires n resources with limited concurrency in parallel and puts them into pool as idle resources.
+// The quantity of resources acquired won't exceed the limit of pool, and a concurrency == 0 means no limit.
+// All errors will be joined and returned if some resources failed to acquire.
+// The channel returned by Pool.Ready will be closed once a warmup completes without any errors.
+func (p *Pool[Resource]) Warmup(ctx context.Context, n uint64, concurrency uint64) error {
+	p.lock.Lock()
+	if p.closed {
+		p.lock.Unlock()
+		return p.closedErr(ctx)
+	}
+
+	// Increase the active in advance so the pool won't be exhausted by warmup.
+	n = min(n, p.limit-p.active)
+	p.active += n
+	p.lock.Unlock()
+
+	if concurrency <= 0 || concurrency > n {
+		concurrency = n
+	}
+
+	var errs []error
+	var errsLock sync.Mutex
+	var wg sync.WaitGroup
+
+	tokens := make(chan struct{}, concurrency)
+	for range n {
+		tokens <- struct{}{}
+
+		wg.Go(func() {
+			defer func() {
+				<-tokens
+			}()
+
+			if err := p.warmupOne(ctx); err != nil {
+				errsLock.Lock()
+				errs = append(errs, err)
+				errsLock.Unlock()
+			}
+		})
+	}
+
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	p.readyOnce.Do(func() {
+		close(p.ready)
+	})
+
+	return nil
+}
+
+// Ready returns a channel which will be closed once a warmup completes without any errors.
+// It's useful for readiness probes so the service won't be ready until the pool has its initial resources.
+func (p *Pool[Resource]) Ready() <-chan struct{} {
+	return p.ready
+}
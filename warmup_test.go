@@ -0,0 +1,132 @@
+// Copyright 2025 FishGoddess. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package rego
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// go test -v -cover -run=^TestPoolWarmup$
+func TestPoolWarmup(t *testing.T) {
+	ctx := context.Background()
+
+	var acquiring int64
+	var maxAcquiring int64
+	acquire := func(context.Context) (int, error) {
+		current := atomic.AddInt64(&acquiring, 1)
+		defer atomic.AddInt64(&acquiring, -1)
+
+		for {
+			old := atomic.LoadInt64(&maxAcquiring)
+			if current <= old || atomic.CompareAndSwapInt64(&maxAcquiring, old, current) {
+				break
+			}
+		}
+
+		time.Sleep(5 * time.Millisecond)
+		return 0, nil
+	}
+
+	release := func(context.Context, int) error { return nil }
+
+	pool := New(8, acquire, release)
+	defer pool.Close(ctx)
+
+	select {
+	case <-pool.Ready():
+		t.Fatal("pool shouldn't be ready")
+	default:
+	}
+
+	if err := pool.Warmup(ctx, 6, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-pool.Ready():
+	default:
+		t.Fatal("pool should be ready")
+	}
+
+	if got := atomic.LoadInt64(&maxAcquiring); got != 2 {
+		t.Fatalf("got %d != want %d", got, 2)
+	}
+
+	status := pool.Status()
+	if status.Idle != 6 || status.Using != 0 {
+		t.Fatalf("status %+v is wrong", status)
+	}
+
+	// The resources acquired shouldn't exceed the limit.
+	if err := pool.Warmup(ctx, 6, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	status = pool.Status()
+	if status.Idle != 8 || status.Using != 0 {
+		t.Fatalf("status %+v is wrong", status)
+	}
+}
+
+// go test -v -cover -run=^TestPoolWarmupError$
+func TestPoolWarmupError(t *testing.T) {
+	ctx := context.Background()
+
+	var acquired int64
+	errOdd := errors.New("odd")
+	acquire := func(context.Context) (int64, error) {
+		resource := atomic.AddInt64(&acquired, 1)
+		if resource%2 == 1 {
+			return 0, errOdd
+		}
+
+		return resource, nil
+	}
+
+	release := func(context.Context, int64) error { return nil }
+
+	pool := New(8, acquire, release)
+	defer pool.Close(ctx)
+
+	err := pool.Warmup(ctx, 4, 1)
+	if !errors.Is(err, errOdd) {
+		t.Fatalf("got %+v is wrong", err)
+	}
+
+	select {
+	case <-pool.Ready():
+		t.Fatal("pool shouldn't be ready")
+	default:
+	}
+
+	status := pool.Status()
+	if status.Idle != 2 || status.Using != 0 {
+		t.Fatalf("status %+v is wrong", status)
+	}
+
+	cancelCtx, cancel := context.WithCancel(ctx)
+	cancel()
+
+	err = pool.Warmup(cancelCtx, 2, 1)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %+v is wrong", err)
+	}
+
+	status = pool.Status()
+	if status.Idle != 2 || status.Using != 0 {
+		t.Fatalf("status %+v is wrong", status)
+	}
+
+	pool.Close(ctx)
+
+	err = pool.Warmup(ctx, 2, 1)
+	if err != ErrPoolClosed {
+		t.Fatalf("got %+v != want %+v", err, ErrPoolClosed)
+	}
+}